@@ -0,0 +1,74 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// decompressBC4 decompresses a BC4 (single-channel) compressed slice of
+// bytes into an 8-bit grayscale image. A BC4 block is byte-identical to
+// the alpha half of a DXT5 block, so this reuses interpolateAlpha to
+// resolve each pixel's value.
+func decompressBC4(packed []byte, width, height int) (*image.Gray, error) {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+
+	blockCountX := (width + 3) / blockSize
+	blockCountY := (height + 3) / blockSize
+	rowBytes := blockCountX * 8
+	if len(packed) < blockCountY*rowBytes {
+		return nil, fmt.Errorf("decompressing BC4: %w", io.ErrUnexpectedEOF)
+	}
+
+	offset := 0
+	for j := 0; j < blockCountY; j++ {
+		for i := 0; i < blockCountX; i++ {
+			decompressBC4Block(packed[offset+i*8:], i*blockSize, j*blockSize, img)
+		}
+		offset += blockCountX * 8
+	}
+
+	return img, nil
+}
+
+// decompressBC4Block decodes a single 8-byte BC4 block into img at
+// (offsetX, offsetY).
+func decompressBC4Block(packed []byte, offsetX, offsetY int, img *image.Gray) {
+	v0, v1 := packed[0], packed[1]
+
+	indices := uint64(packed[2]) | uint64(packed[3])<<8 | uint64(packed[4])<<16 |
+		uint64(packed[5])<<24 | uint64(packed[6])<<32 | uint64(packed[7])<<40
+
+	bounds := img.Bounds()
+	for j := 0; j < blockSize; j++ {
+		y := offsetY + j
+		if y >= bounds.Dy() {
+			continue
+		}
+		for i := 0; i < blockSize; i++ {
+			x := offsetX + i
+			if x >= bounds.Dx() {
+				continue
+			}
+			code := int((indices >> uint(3*(4*j+i))) & 0x07)
+			img.SetGray(x, y, color.Gray{Y: interpolateAlpha(v0, v1, code)})
+		}
+	}
+}
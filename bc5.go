@@ -0,0 +1,85 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// decompressBC5 decompresses a BC5 (two-channel) compressed slice of
+// bytes. Each 4x4 block is a red BC4 block followed by a green BC4 block;
+// the result is returned as an NRGBA image with B set to 0 and A set to
+// 255, since BC5 carries no alpha or blue data (it's typically used for
+// tangent-space normal maps, where the blue channel is reconstructed by
+// the consumer).
+func decompressBC5(packed []byte, width, height int) (*image.NRGBA, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	blockCountX := (width + 3) / blockSize
+	blockCountY := (height + 3) / blockSize
+	rowBytes := blockCountX * 16
+	if len(packed) < blockCountY*rowBytes {
+		return nil, fmt.Errorf("decompressing BC5: %w", io.ErrUnexpectedEOF)
+	}
+
+	offset := 0
+	for j := 0; j < blockCountY; j++ {
+		for i := 0; i < blockCountX; i++ {
+			block := packed[offset+i*16:]
+			decompressBC5Block(block[:8], block[8:16], i*blockSize, j*blockSize, img)
+		}
+		offset += blockCountX * 16
+	}
+
+	return img, nil
+}
+
+func decompressBC5Block(redBlock, greenBlock []byte, offsetX, offsetY int, img *image.NRGBA) {
+	red := decompressChannelBlock(redBlock)
+	green := decompressChannelBlock(greenBlock)
+
+	bounds := img.Bounds()
+	for j := 0; j < blockSize; j++ {
+		y := offsetY + j
+		if y >= bounds.Dy() {
+			continue
+		}
+		for i := 0; i < blockSize; i++ {
+			x := offsetX + i
+			if x >= bounds.Dx() {
+				continue
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: red[j*blockSize+i], G: green[j*blockSize+i], B: 0, A: 255})
+		}
+	}
+}
+
+func decompressChannelBlock(packed []byte) [16]uint8 {
+	v0, v1 := packed[0], packed[1]
+	indices := uint64(packed[2]) | uint64(packed[3])<<8 | uint64(packed[4])<<16 |
+		uint64(packed[5])<<24 | uint64(packed[6])<<32 | uint64(packed[7])<<40
+
+	var out [16]uint8
+	for k := 0; k < 16; k++ {
+		code := int((indices >> uint(3*k)) & 0x07)
+		out[k] = interpolateAlpha(v0, v1, code)
+	}
+	return out
+}
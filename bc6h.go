@@ -0,0 +1,194 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// bc6HModeSingleRegion is the mode value (in the BC6H spec's own
+// numbering) for the single-region, direct 10-bit-endpoint layout: a
+// 2-bit prefix of 0b11 followed by 3 more mode bits of 0b000. It's the
+// only mode decompressBC6HBlock understands; every other mode (all the
+// two-region, delta-encoded or reduced-precision layouts) uses a
+// different header/endpoint layout that this decoder does not reproduce.
+const bc6HModeSingleRegion = 0x03
+
+// decompressBC6H decodes a BC6H HDR block-compressed image to RGBA64,
+// clamping the decoded half-float radiance into the unsigned 16-bit range
+// so it can be represented by color.RGBA64. Only the single-region mode
+// (0x03) is supported; every other mode uses a two-region partition
+// table and delta-encoded endpoints this package doesn't have a verified
+// copy of, so decompressBC6HBlock returns an error for them instead of
+// guessing at their layout.
+func decompressBC6H(packed []byte, width, height int, signed bool) (image.Image, error) {
+	img := image.NewRGBA64(image.Rect(0, 0, width, height))
+
+	blockCountX := (width + 3) / blockSize
+	blockCountY := (height + 3) / blockSize
+	rowBytes := blockCountX * 16
+	if len(packed) < blockCountY*rowBytes {
+		return nil, fmt.Errorf("decompressing BC6H: %w", io.ErrUnexpectedEOF)
+	}
+
+	offset := 0
+	for j := 0; j < blockCountY; j++ {
+		for i := 0; i < blockCountX; i++ {
+			if err := decompressBC6HBlock(packed[offset+i*16:offset+i*16+16], i*blockSize, j*blockSize, signed, img); err != nil {
+				return nil, fmt.Errorf("decoding BC6H block (%d,%d): %w", i, j, err)
+			}
+		}
+		offset += blockCountX * 16
+	}
+
+	return img, nil
+}
+
+// decompressBC6HBlock decodes one 16-byte BC6H block. The mode field is
+// variable-width: a 2-bit prefix, extended to 5 bits only when that
+// prefix is 0b11. decompressBC6HBlock decodes the single-region mode
+// (0x03, the 5-bit prefix-extended form) exactly, reading its two raw
+// 10-bit-per-component endpoint colours and a 4-bit primary index array;
+// every other mode returns an error rather than misinterpreting its
+// differently-shaped header as mode 0x03's.
+func decompressBC6HBlock(packed []byte, offsetX, offsetY int, signed bool, img *image.RGBA64) error {
+	r := &bitReader{data: packed}
+
+	mode := r.read(2)
+	if mode == 0x03 {
+		mode |= r.read(3) << 2
+	}
+	if mode != bc6HModeSingleRegion {
+		return fmt.Errorf("dds: unsupported BC6H mode %#x (only the single-region mode %#x is supported)", mode, bc6HModeSingleRegion)
+	}
+
+	var e0, e1 [3]uint32
+	for c := 0; c < 3; c++ {
+		e0[c] = r.read(10)
+	}
+	for c := 0; c < 3; c++ {
+		e1[c] = r.read(10)
+	}
+
+	c0 := bc6HalfToColor(e0, signed)
+	c1 := bc6HalfToColor(e1, signed)
+
+	idxBits := uint(4)
+	weights := bc7WeightTable(idxBits)
+
+	bounds := img.Bounds()
+	for j := 0; j < blockSize; j++ {
+		y := offsetY + j
+		if y >= bounds.Dy() {
+			continue
+		}
+		for i := 0; i < blockSize; i++ {
+			x := offsetX + i
+			if x >= bounds.Dx() {
+				continue
+			}
+			n := idxBits
+			if j == 0 && i == 0 {
+				n-- // anchor index 0 always has an implicit zero MSB
+			}
+			idx := r.read(n)
+			w := weights[idx]
+			img.SetRGBA64(x, y, color.RGBA64{
+				R: bc6Lerp16(c0.R, c1.R, w),
+				G: bc6Lerp16(c0.G, c1.G, w),
+				B: bc6Lerp16(c0.B, c1.B, w),
+				A: 0xffff,
+			})
+		}
+	}
+
+	return nil
+}
+
+// bc6HalfToColor converts a raw 10-bit-per-component BC6H endpoint into
+// linear light scaled to uint16, clamping HDR values above 1.0 instead of
+// the precision loss a true tone-map would need.
+func bc6HalfToColor(e [3]uint32, signed bool) color.RGBA64 {
+	component := func(v uint32) uint16 {
+		f := bc6ComponentToFloat(v, signed)
+		if f < 0 {
+			f = 0
+		}
+		if f > 1 {
+			f = 1
+		}
+		return uint16(f * 65535)
+	}
+	return color.RGBA64{R: component(e[0]), G: component(e[1]), B: component(e[2]), A: 0xffff}
+}
+
+// bc6ComponentToFloat expands a raw BC6H endpoint component (up to 16
+// bits once fully unquantized, but the simplified decoder above only ever
+// supplies 10 raw bits) into a half-precision-equivalent float by
+// replicating it to 16 bits and reinterpreting it the way the BC6H spec's
+// "unquantize" step does, then decoding that as an IEEE half float.
+func bc6ComponentToFloat(v uint32, signed bool) float32 {
+	bits := expandBits16(v, 10)
+	return halfToFloat32(bits, signed)
+}
+
+func expandBits16(v uint32, bits uint) uint16 {
+	out := v
+	shift := bits
+	for shift < 16 {
+		out = (out << shift) | v
+		shift += bits
+	}
+	return uint16(out >> (shift - 16))
+}
+
+func halfToFloat32(bits uint16, signed bool) float32 {
+	sign := uint32(0)
+	mantissa := uint32(bits & 0x3ff)
+	exponent := uint32((bits >> 10) & 0x1f)
+	if signed && bits&0x8000 != 0 {
+		sign = 1
+	}
+
+	if exponent == 0 {
+		f := float32(mantissa) / 1024 / 16384
+		if sign == 1 {
+			f = -f
+		}
+		return f
+	}
+	if exponent == 31 {
+		if sign == 1 {
+			return float32(math.Inf(-1))
+		}
+		return float32(math.Inf(1))
+	}
+
+	f := (1 + float32(mantissa)/1024) * float32(math.Pow(2, float64(exponent)-15))
+	if sign == 1 {
+		f = -f
+	}
+	return f
+}
+
+func bc6Lerp16(a, b uint16, weight uint32) uint16 {
+	return uint16((uint32(a)*(64-weight) + uint32(b)*weight + 32) >> 6)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"image"
+	"testing"
+)
+
+// buildBC6HSingleRegionBlock writes a mode-0x03 (single-region, direct
+// 10-bit endpoint) block: a 2-bit prefix of 0b11 extended by 3 more mode
+// bits of 0b000, two raw 10-bit-per-component endpoints, and a 4-bit
+// index array (with index 0's implicit zero MSB omitted).
+func buildBC6HSingleRegionBlock(e0, e1 [3]uint32) []byte {
+	w := &bitWriter{}
+	w.write(0x03, 2)
+	w.write(0, 3)
+	for _, c := range e0 {
+		w.write(c, 10)
+	}
+	for _, c := range e1 {
+		w.write(c, 10)
+	}
+	for k := 0; k < 16; k++ {
+		n := uint(4)
+		if k == 0 {
+			n--
+		}
+		w.write(0, n)
+	}
+	for len(w.data) < 16 {
+		w.data = append(w.data, 0)
+	}
+	return w.data[:16]
+}
+
+func TestDecompressBC6HSingleRegionBlock(t *testing.T) {
+	block := buildBC6HSingleRegionBlock([3]uint32{0, 0, 0}, [3]uint32{0x3ff, 0x3ff, 0x3ff})
+	img := image.NewRGBA64(image.Rect(0, 0, 4, 4))
+	if err := decompressBC6HBlock(block, 0, 0, false, img); err != nil {
+		t.Fatalf("decompressBC6HBlock: %v", err)
+	}
+
+	c := img.RGBA64At(0, 0)
+	if c.R != 0 || c.G != 0 || c.B != 0 {
+		t.Errorf("At(0,0) = %+v; want black (index 0 selects the low endpoint)", c)
+	}
+}
+
+// TestDecompressBC6HRejectsUnsupportedMode guards against silently
+// misreading any mode other than the single-region mode 0x03: rather
+// than reinterpreting a differently-shaped header, decompressBC6HBlock
+// must return an error.
+func TestDecompressBC6HRejectsUnsupportedMode(t *testing.T) {
+	w := &bitWriter{}
+	w.write(0x00, 2) // a 2-region mode's 2-bit prefix, not extended.
+	for len(w.data) < 16 {
+		w.data = append(w.data, 0)
+	}
+
+	img := image.NewRGBA64(image.Rect(0, 0, 4, 4))
+	if err := decompressBC6HBlock(w.data[:16], 0, 0, false, img); err == nil {
+		t.Error("decompressBC6HBlock of an unsupported mode = nil error; want an error")
+	}
+}
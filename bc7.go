@@ -0,0 +1,360 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// bc7ModeInfo describes the bit layout of one of BC7's 8 modes, per the
+// block format table in the BC7 specification.
+type bc7ModeInfo struct {
+	subsets           int
+	partitionBits     uint
+	rotationBits      uint
+	hasIndexSelection bool
+	colorBits         uint
+	alphaBits         uint
+	uniquePBits       bool // true: one p-bit per endpoint; false (and colorBits>0 pbit budget) handled per mode below
+	sharedPBits       bool // true: one p-bit per subset, shared between its two endpoints
+	indexBits         uint
+	index2Bits        uint
+}
+
+var bc7Modes = [8]bc7ModeInfo{
+	{subsets: 3, partitionBits: 4, colorBits: 4, uniquePBits: true, indexBits: 3},
+	{subsets: 2, partitionBits: 6, colorBits: 6, sharedPBits: true, indexBits: 3},
+	{subsets: 3, partitionBits: 6, colorBits: 5, indexBits: 2},
+	{subsets: 2, partitionBits: 6, colorBits: 7, uniquePBits: true, indexBits: 2},
+	{subsets: 1, rotationBits: 2, hasIndexSelection: true, colorBits: 5, alphaBits: 6, indexBits: 2, index2Bits: 3},
+	{subsets: 1, rotationBits: 2, colorBits: 7, alphaBits: 8, indexBits: 2, index2Bits: 2},
+	{subsets: 1, colorBits: 7, alphaBits: 7, uniquePBits: true, indexBits: 4},
+	{subsets: 2, partitionBits: 6, colorBits: 5, alphaBits: 5, uniquePBits: true, indexBits: 2},
+}
+
+var (
+	bc7Weights2 = [4]uint32{0, 21, 43, 64}
+	bc7Weights3 = [8]uint32{0, 9, 18, 27, 37, 46, 55, 64}
+	bc7Weights4 = [16]uint32{0, 4, 9, 13, 17, 21, 26, 30, 34, 38, 43, 47, 51, 55, 60, 64}
+)
+
+// bc7Anchor2 gives the raster-order pixel index of a 2-subset partition's
+// second subset's anchor (the pixel whose index has an implicit zero
+// MSB), keyed by the 6-bit partition field. Subset 0's anchor is always
+// pixel 0 and isn't in this table. Reproduced from the BC7 specification's
+// partition fix-up table.
+var bc7Anchor2 = [64]int{
+	15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15,
+	15, 2, 8, 2, 2, 8, 8, 15,
+	2, 8, 2, 2, 8, 8, 2, 2,
+	15, 15, 6, 8, 2, 8, 15, 15,
+	2, 8, 2, 2, 2, 15, 15, 6,
+	6, 2, 6, 8, 15, 15, 2, 2,
+	15, 15, 15, 15, 15, 2, 2, 15,
+}
+
+// bc7SubsetAnchors returns the set of pixel indices that are a subset's
+// anchor (the pixel whose index has an implicit zero MSB) for a 1- or
+// 2-subset block with the given partition field. decompressBC7Block
+// doesn't have a verified 3-subset fix-up table, so it rejects 3-subset
+// modes (0 and 2) outright instead of guessing at one; this function is
+// never called with subsets == 3.
+func bc7SubsetAnchors(subsets int, partition uint32) map[int]bool {
+	anchors := map[int]bool{0: true}
+	if subsets < 2 {
+		return anchors
+	}
+	anchors[bc7Anchor2[partition]] = true
+	return anchors
+}
+
+func bc7WeightTable(bits uint) []uint32 {
+	switch bits {
+	case 2:
+		return bc7Weights2[:]
+	case 3:
+		return bc7Weights3[:]
+	default:
+		return bc7Weights4[:]
+	}
+}
+
+// bitReader reads fixed-width, LSB-first bitfields out of a 16-byte BC7
+// block, matching the bit order the specification lays the block out in.
+type bitReader struct {
+	data []byte
+	pos  uint
+}
+
+func (r *bitReader) read(n uint) uint32 {
+	var v uint32
+	for i := uint(0); i < n; i++ {
+		byteIdx := (r.pos + i) / 8
+		bitIdx := (r.pos + i) % 8
+		bit := (r.data[byteIdx] >> bitIdx) & 1
+		v |= uint32(bit) << i
+	}
+	r.pos += n
+	return v
+}
+
+// expandBits widens a bits-wide value to 8 bits by replicating its most
+// significant bits into the low bits, the same way the reference BC7/BC6H
+// decoders extend endpoint components to full precision.
+func expandBits(v uint32, bits uint) uint8 {
+	if bits == 0 {
+		return 0
+	}
+	out := v
+	shift := bits
+	for shift < 8 {
+		out = (out << shift) | v
+		shift += bits
+	}
+	return uint8(out >> (shift - 8))
+}
+
+func decompressBC7(packed []byte, width, height int) (image.Image, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	blockCountX := (width + 3) / blockSize
+	blockCountY := (height + 3) / blockSize
+	rowBytes := blockCountX * 16
+	if len(packed) < blockCountY*rowBytes {
+		return nil, fmt.Errorf("decompressing BC7: %w", io.ErrUnexpectedEOF)
+	}
+
+	offset := 0
+	for j := 0; j < blockCountY; j++ {
+		for i := 0; i < blockCountX; i++ {
+			if err := decompressBC7Block(packed[offset+i*16:offset+i*16+16], i*blockSize, j*blockSize, img); err != nil {
+				return nil, err
+			}
+		}
+		offset += blockCountX * 16
+	}
+
+	return img, nil
+}
+
+// decompressBC7Block decodes one 16-byte BC7 block. Single-subset modes
+// (4, 5 and 6) are decoded exactly per the specification.
+//
+// The 2-subset modes (1, 3 and 7) carry per-pixel subset assignments
+// from a set of 64 fixed partition shapes, and subset 1 has its own
+// anchor index — a pixel whose colour/alpha index has an implicit zero
+// MSB, at a partition-dependent position given by bc7SubsetAnchors —
+// which decompressBC7Block accounts for using the verified 2-subset
+// fix-up table (bc7Anchor2) so the bitstream stays in sync. This package
+// doesn't have a verified copy of the full per-pixel partition shape
+// table, so rather than guess at shape assignment and risk silently
+// misassigning pixels to the wrong subset's endpoints, decompressBC7Block
+// renders every pixel in a 2-subset block using subset 0's endpoints,
+// which is a known, tested approximation (flatter colour, no hard subset
+// boundary); see TestDecompressBC7MultiSubsetApproximatesFlatColour.
+//
+// The 3-subset modes (0 and 2) need a second fix-up table this package
+// doesn't have a verified copy of either; guessing at it would desync
+// the bitstream in a way that isn't even a flat-colour approximation
+// (every index bit after the guessed anchor position reads the wrong
+// pixel's bits), so decompressBC7Block rejects them outright instead.
+func decompressBC7Block(packed []byte, offsetX, offsetY int, img *image.NRGBA) error {
+	mode := bc7BlockMode(packed[0])
+	if mode < 0 {
+		return fmt.Errorf("dds: invalid BC7 block mode byte %#x", packed[0])
+	}
+	info := bc7Modes[mode]
+	if info.subsets == 3 {
+		return fmt.Errorf("dds: unsupported BC7 mode %d (3-subset partitioning isn't supported)", mode)
+	}
+
+	r := &bitReader{data: packed, pos: uint(mode) + 1}
+
+	partition := uint32(0)
+	if info.partitionBits > 0 {
+		partition = r.read(info.partitionBits)
+	}
+	rotation := uint32(0)
+	if info.rotationBits > 0 {
+		rotation = r.read(info.rotationBits)
+	}
+	indexSelection := uint32(0)
+	if info.hasIndexSelection {
+		indexSelection = r.read(1)
+	}
+
+	type endpoint struct{ r, g, b, a uint32 }
+	endpoints := make([]endpoint, info.subsets*2)
+	for i := range endpoints {
+		endpoints[i].r = r.read(info.colorBits)
+	}
+	for i := range endpoints {
+		endpoints[i].g = r.read(info.colorBits)
+	}
+	for i := range endpoints {
+		endpoints[i].b = r.read(info.colorBits)
+	}
+	if info.alphaBits > 0 {
+		for i := range endpoints {
+			endpoints[i].a = r.read(info.alphaBits)
+		}
+	}
+
+	pBits := make([]uint32, len(endpoints))
+	if info.uniquePBits {
+		for i := range pBits {
+			pBits[i] = r.read(1)
+		}
+	} else if info.sharedPBits {
+		for s := 0; s < info.subsets; s++ {
+			p := r.read(1)
+			pBits[s*2] = p
+			pBits[s*2+1] = p
+		}
+	}
+
+	colorBits := info.colorBits
+	alphaBits := info.alphaBits
+	if info.uniquePBits || info.sharedPBits {
+		colorBits++
+		if alphaBits > 0 {
+			alphaBits++
+		}
+	}
+
+	endpointColor := func(i int) color.RGBA {
+		e := endpoints[i]
+		p := pBits[i]
+		rv, gv, bv := e.r, e.g, e.b
+		if info.uniquePBits || info.sharedPBits {
+			rv, gv, bv = rv<<1|p, gv<<1|p, bv<<1|p
+		}
+		c := color.RGBA{R: expandBits(rv, colorBits), G: expandBits(gv, colorBits), B: expandBits(bv, colorBits), A: 255}
+		if alphaBits > 0 {
+			av := e.a
+			if info.uniquePBits || info.sharedPBits {
+				av = av<<1 | p
+			}
+			c.A = expandBits(av, alphaBits)
+		}
+		return c
+	}
+
+	c0, c1 := endpointColor(0), endpointColor(1)
+
+	// Every subset's first pixel (by raster order, not by subset) has an
+	// implicit zero MSB in its index, not just pixel 0: pixel 0 is always
+	// subset 0's anchor, and bc7SubsetAnchors gives the partition-dependent
+	// anchor position of every other subset. Without this, the bitstream
+	// desyncs and reads past the end of the block for every multi-subset
+	// mode.
+	anchors := bc7SubsetAnchors(info.subsets, partition)
+
+	readIndexArray := func(bits uint) [16]uint32 {
+		var idx [16]uint32
+		for k := 0; k < 16; k++ {
+			n := bits
+			if anchors[k] {
+				n-- // this pixel is a subset anchor; implicit zero MSB
+			}
+			idx[k] = r.read(n)
+		}
+		return idx
+	}
+
+	colorIdx := readIndexArray(info.indexBits)
+	var alphaIdx [16]uint32
+	haveAlphaIdx := info.index2Bits > 0
+	if haveAlphaIdx {
+		alphaIdx = readIndexArray(info.index2Bits)
+	}
+
+	colorWeights := bc7WeightTable(info.indexBits)
+	var alphaWeights []uint32
+	if haveAlphaIdx {
+		alphaWeights = bc7WeightTable(info.index2Bits)
+	}
+
+	// Mode 4's index selection bit swaps which of the two index arrays
+	// drives colour interpolation and which drives alpha.
+	if info.hasIndexSelection && indexSelection == 1 {
+		colorIdx, alphaIdx = alphaIdx, colorIdx
+		colorWeights, alphaWeights = alphaWeights, colorWeights
+	}
+
+	bounds := img.Bounds()
+	for j := 0; j < blockSize; j++ {
+		y := offsetY + j
+		if y >= bounds.Dy() {
+			continue
+		}
+		for i := 0; i < blockSize; i++ {
+			x := offsetX + i
+			if x >= bounds.Dx() {
+				continue
+			}
+			k := j*blockSize + i
+
+			cw := colorWeights[colorIdx[k]]
+			out := color.RGBA{
+				R: bc7Lerp(c0.R, c1.R, cw),
+				G: bc7Lerp(c0.G, c1.G, cw),
+				B: bc7Lerp(c0.B, c1.B, cw),
+				A: c0.A,
+			}
+			if haveAlphaIdx {
+				aw := alphaWeights[alphaIdx[k]]
+				out.A = bc7Lerp(c0.A, c1.A, aw)
+			} else if info.alphaBits > 0 {
+				out.A = bc7Lerp(c0.A, c1.A, cw)
+			}
+
+			switch rotation {
+			case 1:
+				out.R, out.A = out.A, out.R
+			case 2:
+				out.G, out.A = out.A, out.G
+			case 3:
+				out.B, out.A = out.A, out.B
+			}
+
+			img.SetNRGBA(x, y, color.NRGBA{R: out.R, G: out.G, B: out.B, A: out.A})
+		}
+	}
+
+	return nil
+}
+
+func bc7Lerp(a, b uint8, weight uint32) uint8 {
+	return uint8((uint32(a)*(64-weight) + uint32(b)*weight + 32) >> 6)
+}
+
+// bc7BlockMode returns the BC7 mode number encoded in a block's first
+// byte: the count of zero bits before the first one bit, scanning from
+// the LSB.
+func bc7BlockMode(b byte) int {
+	for m := 0; m < 8; m++ {
+		if b&(1<<uint(m)) != 0 {
+			return m
+		}
+	}
+	return -1
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"image"
+	"testing"
+)
+
+// bitWriter is bitReader's write-side counterpart, used by tests to
+// hand-assemble block bitstreams LSB-first the way the real format lays
+// them out.
+type bitWriter struct {
+	data []byte
+	pos  uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	for len(w.data) < int((w.pos+n+7)/8) {
+		w.data = append(w.data, 0)
+	}
+	for i := uint(0); i < n; i++ {
+		if (v>>i)&1 != 0 {
+			byteIdx := (w.pos + i) / 8
+			bitIdx := (w.pos + i) % 8
+			w.data[byteIdx] |= 1 << bitIdx
+		}
+	}
+	w.pos += n
+}
+
+func TestDecompressBC4(t *testing.T) {
+	// One block: low=0x00, high=0xff, all indices 0 (selects the low
+	// endpoint, alpha0).
+	block := []byte{0x00, 0xff, 0, 0, 0, 0, 0, 0}
+	img, err := decompressBC4(block, 4, 4)
+	if err != nil {
+		t.Fatalf("decompressBC4: %v", err)
+	}
+	if got := img.GrayAt(0, 0).Y; got != 0x00 {
+		t.Errorf("GrayAt(0,0) = %#x; want 0x00", got)
+	}
+}
+
+// TestDecompressBC4ShortPayloadReturnsError guards against the panic a
+// truncated block-compressed payload used to cause: indexing into a
+// too-short packed slice instead of returning an error lets a malformed
+// DDS file crash Decode instead of surfacing cleanly (and, under
+// Decoder.Strict, being classified as such).
+func TestDecompressBC4ShortPayloadReturnsError(t *testing.T) {
+	if _, err := decompressBC4([]byte{0, 0xff}, 8, 8); err == nil {
+		t.Error("decompressBC4 of a truncated payload = nil error; want an error")
+	}
+}
+
+// TestDecompressBC5ShortPayloadReturnsError mirrors
+// TestDecompressBC4ShortPayloadReturnsError for BC5.
+func TestDecompressBC5ShortPayloadReturnsError(t *testing.T) {
+	if _, err := decompressBC5([]byte{0, 0xff}, 8, 8); err == nil {
+		t.Error("decompressBC5 of a truncated payload = nil error; want an error")
+	}
+}
+
+// TestDecompressBC7ShortPayloadReturnsError guards against the panic a
+// truncated BC7 payload used to cause (slicing packed[offset+i*16:+16]
+// past the end of a too-short buffer) instead of returning an error.
+func TestDecompressBC7ShortPayloadReturnsError(t *testing.T) {
+	if _, err := decompressBC7([]byte{1, 0}, 8, 8); err == nil {
+		t.Error("decompressBC7 of a truncated payload = nil error; want an error")
+	}
+}
+
+// TestDecompressBC6HShortPayloadReturnsError mirrors
+// TestDecompressBC7ShortPayloadReturnsError for BC6H.
+func TestDecompressBC6HShortPayloadReturnsError(t *testing.T) {
+	if _, err := decompressBC6H([]byte{0, 0}, 8, 8, false); err == nil {
+		t.Error("decompressBC6H of a truncated payload = nil error; want an error")
+	}
+}
+
+func TestBC7BlockMode(t *testing.T) {
+	for _, test := range []struct {
+		b    byte
+		want int
+	}{
+		{0x01, 0},
+		{0x02, 1},
+		{0x04, 2},
+		{0x40, 6},
+		{0x80, 7},
+		{0x00, -1},
+	} {
+		if got := bc7BlockMode(test.b); got != test.want {
+			t.Errorf("bc7BlockMode(%#x) = %d; want %d", test.b, got, test.want)
+		}
+	}
+}
+
+// buildBC7Block writes a minimal valid block for mode, with every field
+// after the mode byte zeroed, using bc7Modes to size each field the same
+// way decompressBC7Block reads it.
+func buildBC7Block(mode int) []byte {
+	info := bc7Modes[mode]
+	w := &bitWriter{}
+	w.write(1<<uint(mode), uint(mode)+1)
+
+	if info.partitionBits > 0 {
+		w.write(0, info.partitionBits)
+	}
+	if info.rotationBits > 0 {
+		w.write(0, info.rotationBits)
+	}
+	if info.hasIndexSelection {
+		w.write(0, 1)
+	}
+
+	endpointCount := info.subsets * 2
+	for c := 0; c < 3; c++ {
+		for i := 0; i < endpointCount; i++ {
+			w.write(0, info.colorBits)
+		}
+	}
+	if info.alphaBits > 0 {
+		for i := 0; i < endpointCount; i++ {
+			w.write(0, info.alphaBits)
+		}
+	}
+
+	if info.uniquePBits {
+		for i := 0; i < endpointCount; i++ {
+			w.write(0, 1)
+		}
+	} else if info.sharedPBits {
+		for s := 0; s < info.subsets; s++ {
+			w.write(0, 1)
+		}
+	}
+
+	anchors := bc7SubsetAnchors(info.subsets, 0) // partition field written as 0 above
+	writeIndexArray := func(bits uint) {
+		for k := 0; k < 16; k++ {
+			n := bits
+			if anchors[k] {
+				n--
+			}
+			w.write(0, n)
+		}
+	}
+	writeIndexArray(info.indexBits)
+	if info.index2Bits > 0 {
+		writeIndexArray(info.index2Bits)
+	}
+
+	for len(w.data) < 16 {
+		w.data = append(w.data, 0)
+	}
+	return w.data[:16]
+}
+
+// TestDecompressBC7AllModesDecodeOrRejectSubsets3 exercises every mode's
+// bit layout without panicking or overrunning the block: the 3-subset
+// modes (0 and 2) are expected to return an error, since this package
+// lacks a verified 3-subset fix-up table (see decompressBC7Block's doc
+// comment), and every other mode should decode cleanly.
+func TestDecompressBC7AllModesDecodeOrRejectSubsets3(t *testing.T) {
+	for mode := 0; mode < 8; mode++ {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		err := decompressBC7Block(buildBC7Block(mode), 0, 0, img)
+		wantErr := bc7Modes[mode].subsets == 3
+		if wantErr && err == nil {
+			t.Errorf("mode %d: decompressBC7Block = nil error; want an error (3-subset modes are unsupported)", mode)
+		}
+		if !wantErr && err != nil {
+			t.Errorf("mode %d: decompressBC7Block: %v", mode, err)
+		}
+	}
+}
+
+// TestDecompressBC7MultiSubsetApproximatesFlatColour pins the documented
+// limitation of decompressBC7Block: lacking the spec's partition shape
+// tables, it renders every pixel in a multi-subset block using subset
+// 0's endpoints, so two blocks that only differ in a non-subset-0
+// endpoint decode identically. If the partition table is ever added,
+// this test should be replaced with one that asserts the opposite.
+func TestDecompressBC7MultiSubsetApproximatesFlatColour(t *testing.T) {
+	const mode = 1 // 2 subsets, 6-bit partition field.
+	const partition = 5
+
+	build := func(subset1Red uint32) []byte {
+		info := bc7Modes[mode]
+		w := &bitWriter{}
+		w.write(1<<uint(mode), uint(mode)+1)
+		w.write(partition, info.partitionBits)
+		endpointCount := info.subsets * 2
+		red := []uint32{10, 50, subset1Red, subset1Red}
+		for c := 0; c < 3; c++ {
+			for i := 0; i < endpointCount; i++ {
+				v := uint32(0)
+				if c == 0 {
+					v = red[i]
+				}
+				w.write(v, info.colorBits)
+			}
+		}
+		for s := 0; s < info.subsets; s++ {
+			w.write(0, 1)
+		}
+		anchors := bc7SubsetAnchors(info.subsets, partition)
+		for k := 0; k < 16; k++ {
+			n := info.indexBits
+			if anchors[k] {
+				n--
+			}
+			w.write(uint32(k)%(1<<n), n)
+		}
+		for len(w.data) < 16 {
+			w.data = append(w.data, 0)
+		}
+		return w.data[:16]
+	}
+
+	imgA := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	if err := decompressBC7Block(build(0), 0, 0, imgA); err != nil {
+		t.Fatalf("decompressBC7Block: %v", err)
+	}
+	imgB := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	if err := decompressBC7Block(build(60), 0, 0, imgB); err != nil {
+		t.Fatalf("decompressBC7Block: %v", err)
+	}
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if imgA.NRGBAAt(x, y) != imgB.NRGBAAt(x, y) {
+				t.Fatalf("pixel (%d,%d) differs between subset-1-only endpoint changes: %v vs %v; decompressBC7Block should currently ignore non-subset-0 endpoints", x, y, imgA.NRGBAAt(x, y), imgB.NRGBAAt(x, y))
+			}
+		}
+	}
+}
+
+func TestExpandBits(t *testing.T) {
+	if got := expandBits(0x1f, 5); got != 0xff {
+		t.Errorf("expandBits(0x1f, 5) = %#x; want 0xff", got)
+	}
+	if got := expandBits(0, 5); got != 0 {
+		t.Errorf("expandBits(0, 5) = %#x; want 0", got)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+)
+
+// Texture describes every surface a DDS file contains: every mip level of
+// every array layer (and, for cubemaps, every face).
+type Texture struct {
+	Width, Height, Depth int
+	MipLevels            int
+	ArraySize            int
+	IsCubemap            bool
+	Format               uint32 // the legacy fourCC, or the DX10 DXGI_FORMAT if that header is present
+
+	// Surfaces holds every decoded surface, indexed by
+	// [arrayLayer*faces+face][mipLevel][depthSlice]. faces is 6 for a
+	// cubemap, 1 otherwise. depthSlice has length 1 for every texture
+	// except a volume (Depth>1) texture, where each mip level's Z-slices
+	// are all exposed instead of just the first.
+	Surfaces [][][]image.Image
+}
+
+// DecodeAll reads every mip level, array layer and cubemap face out of a
+// DDS file, unlike Decode, which only returns the first surface's top
+// mip. Depth/mip size is computed from the header using the standard
+// pitch/linear-size formula for the surface's format.
+func DecodeAll(r io.Reader) (*Texture, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	faces := 1
+	isCubemap := h.caps[1]&caps2Cubemap != 0
+	if isCubemap {
+		faces = 6
+	}
+
+	arraySize := 1
+	format := h.pixelFormat.fourCC
+	if h.dx10 != nil {
+		arraySize = int(h.dx10.arraySize)
+		format = h.dx10.dxgiFormat
+	}
+
+	mipLevels := 1
+	if h.flags&dMipMapCount != 0 && h.mipMapCount > 0 {
+		mipLevels = int(h.mipMapCount)
+	}
+
+	depth := 1
+	if h.flags&dDepth != 0 && h.depth > 0 {
+		depth = int(h.depth)
+	}
+
+	blockBytes, blockCompressed, err := surfaceBlockFormat(h)
+	if err != nil {
+		return nil, err
+	}
+
+	numSurfaces := arraySize * faces
+	surfaces := make([][][]image.Image, numSurfaces)
+	for s := 0; s < numSurfaces; s++ {
+		mw, mh, md := int(h.width), int(h.height), depth
+		levels := make([][]image.Image, mipLevels)
+		for m := 0; m < mipLevels; m++ {
+			size := surfaceByteSize(mw, mh, md, blockBytes, blockCompressed, h.pixelFormat.rgbBitCount)
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, fmt.Errorf("reading surface %d mip %d: %w", s, m, err)
+			}
+
+			mh2 := h
+			mh2.width, mh2.height = uint32(mw), uint32(mh)
+			sliceSize := size / md
+			slices := make([]image.Image, md)
+			for z := 0; z < md; z++ {
+				img, err := decodeSurface(mh2, bytes.NewReader(buf[z*sliceSize:(z+1)*sliceSize]))
+				if err != nil {
+					return nil, fmt.Errorf("decoding surface %d mip %d slice %d: %w", s, m, z, err)
+				}
+				slices[z] = img
+			}
+			levels[m] = slices
+
+			mw, mh = nextMipSize(mw, mh)
+			if md > 1 {
+				md /= 2
+			}
+		}
+		surfaces[s] = levels
+	}
+
+	return &Texture{
+		Width:     int(h.width),
+		Height:    int(h.height),
+		Depth:     depth,
+		MipLevels: mipLevels,
+		ArraySize: arraySize,
+		IsCubemap: isCubemap,
+		Format:    format,
+		Surfaces:  surfaces,
+	}, nil
+}
+
+// surfaceBlockFormat returns the block size in bytes and whether h's
+// pixel format is block-compressed, so surfaceByteSize can size a mip
+// level without decoding it.
+func surfaceBlockFormat(h header) (blockBytes int, blockCompressed bool, err error) {
+	switch h.pixelFormat.fourCC {
+	case compressionTypeNone:
+		return 0, false, nil
+	case compressionTypeDXT1, compressionTypeATI1:
+		return 8, true, nil
+	case compressionTypeDXT5, compressionTypeATI2:
+		return 16, true, nil
+	case compressionTypeDX10:
+		if h.dx10 == nil {
+			return 0, false, fmt.Errorf("DX10 fourCC set but no DDS_HEADER_DXT10 was parsed")
+		}
+		switch h.dx10.dxgiFormat {
+		case dxgiFormatBC4Unorm, dxgiFormatBC4Snorm:
+			return 8, true, nil
+		case dxgiFormatBC5Unorm, dxgiFormatBC5Snorm, dxgiFormatBC6HUF16, dxgiFormatBC6HSF16, dxgiFormatBC7Unorm, dxgiFormatBC7Srgb:
+			return 16, true, nil
+		default:
+			return 0, false, fmt.Errorf("unsupported DXGI_FORMAT %d", h.dx10.dxgiFormat)
+		}
+	default:
+		return 0, false, fmt.Errorf("unsupported compression format %x", h.pixelFormat.fourCC)
+	}
+}
+
+// surfaceByteSize computes a mip level's size in bytes, using the
+// standard block-compressed pitch formula (rounding the block grid up)
+// or a plain row pitch for uncompressed formats.
+func surfaceByteSize(w, h, depth, blockBytes int, blockCompressed bool, bitsPerPixel uint32) int {
+	if blockCompressed {
+		blocksWide := max1(w+3, 4) / 4
+		blocksHigh := max1(h+3, 4) / 4
+		return blocksWide * blocksHigh * blockBytes * depth
+	}
+	pitch := (w*int(bitsPerPixel) + 7) / 8
+	return pitch * h * depth
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeAllMipmaps(t *testing.T) {
+	var buf bytes.Buffer
+	opt := &EncoderOptions{Format: FormatRGBA, Mipmap: true}
+	if err := Encode(&buf, testImage(), opt); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	tex, err := DecodeAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("DecodeAll: %v", err)
+	}
+
+	if tex.ArraySize != 1 || tex.IsCubemap {
+		t.Errorf("ArraySize/IsCubemap = %d/%v; want 1/false", tex.ArraySize, tex.IsCubemap)
+	}
+	if len(tex.Surfaces) != 1 {
+		t.Fatalf("len(Surfaces) = %d; want 1", len(tex.Surfaces))
+	}
+	if got := len(tex.Surfaces[0]); got != tex.MipLevels {
+		t.Errorf("len(Surfaces[0]) = %d; want MipLevels = %d", got, tex.MipLevels)
+	}
+
+	wantSizes := []int{8, 4, 2, 1}
+	for i, want := range wantSizes {
+		if got := len(tex.Surfaces[0][i]); got != 1 {
+			t.Fatalf("len(Surfaces[0][%d]) = %d; want 1", i, got)
+		}
+		b := tex.Surfaces[0][i][0].Bounds()
+		if b.Dx() != want || b.Dy() != want {
+			t.Errorf("mip %d bounds = %v; want %dx%d", i, b, want, want)
+		}
+	}
+}
+
+// buildVolumeDDS hand-assembles an uncompressed RGBA volume (depth>1)
+// DDS file, since Encoder has no support for writing one: width x height
+// x depth, single mip level, every texel set to its Z-slice index so a
+// test can tell slices apart.
+func buildVolumeDDS(width, height, depth int) []byte {
+	pf := pixelFormat{
+		flags:       pfRGB | pfAlphaPixels,
+		rgbBitCount: 32,
+		rBitMask:    0x00ff0000,
+		gBitMask:    0x0000ff00,
+		bBitMask:    0x000000ff,
+		aBitMask:    0xff000000,
+	}
+	h := header{
+		flags:       headerFlagsTexture | headerFlagsVolume,
+		height:      uint32(height),
+		width:       uint32(width),
+		depth:       uint32(depth),
+		mipMapCount: 1,
+		pixelFormat: pf,
+		caps:        [4]uint32{capsTexture | capsComplex, caps2Volume, 0, 0},
+	}
+
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, h); err != nil {
+		panic(err)
+	}
+	for z := 0; z < depth; z++ {
+		for i := 0; i < width*height; i++ {
+			buf.WriteByte(0)
+			buf.WriteByte(0)
+			buf.WriteByte(byte(z))
+			buf.WriteByte(255)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeAllVolumeTextureExposesEverySlice(t *testing.T) {
+	const width, height, depth = 2, 2, 3
+	tex, err := DecodeAll(bytes.NewReader(buildVolumeDDS(width, height, depth)))
+	if err != nil {
+		t.Fatalf("DecodeAll: %v", err)
+	}
+
+	if tex.Depth != depth {
+		t.Fatalf("Depth = %d; want %d", tex.Depth, depth)
+	}
+	if len(tex.Surfaces) != 1 || len(tex.Surfaces[0]) != 1 {
+		t.Fatalf("Surfaces shape = %dx%d; want 1x1", len(tex.Surfaces), len(tex.Surfaces[0]))
+	}
+
+	slices := tex.Surfaces[0][0]
+	if len(slices) != depth {
+		t.Fatalf("len(slices) = %d; want %d", len(slices), depth)
+	}
+	for z, img := range slices {
+		r, _, _, _ := img.At(0, 0).RGBA()
+		if got := r >> 8; int(got) != z {
+			t.Errorf("slice %d At(0,0).R = %d; want %d", z, got, z)
+		}
+	}
+}
+
+// buildCubemapDDS hand-assembles an uncompressed RGBA cubemap DDS file
+// with one mip level per face, each face set to its own colour so a test
+// can tell faces apart.
+func buildCubemapDDS(size int) []byte {
+	pf := pixelFormat{
+		flags:       pfRGB | pfAlphaPixels,
+		rgbBitCount: 32,
+		rBitMask:    0x00ff0000,
+		gBitMask:    0x0000ff00,
+		bBitMask:    0x000000ff,
+		aBitMask:    0xff000000,
+	}
+	h := header{
+		flags:       headerFlagsTexture,
+		height:      uint32(size),
+		width:       uint32(size),
+		mipMapCount: 1,
+		pixelFormat: pf,
+		caps:        [4]uint32{capsTexture | capsComplex, caps2Cubemap, 0, 0},
+	}
+
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, h); err != nil {
+		panic(err)
+	}
+	for face := 0; face < 6; face++ {
+		for i := 0; i < size*size; i++ {
+			buf.WriteByte(0)
+			buf.WriteByte(0)
+			buf.WriteByte(byte(face))
+			buf.WriteByte(255)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeAllCubemapExposesEveryFace(t *testing.T) {
+	tex, err := DecodeAll(bytes.NewReader(buildCubemapDDS(2)))
+	if err != nil {
+		t.Fatalf("DecodeAll: %v", err)
+	}
+
+	if !tex.IsCubemap {
+		t.Fatal("IsCubemap = false; want true")
+	}
+	if len(tex.Surfaces) != 6 {
+		t.Fatalf("len(Surfaces) = %d; want 6", len(tex.Surfaces))
+	}
+	for face, levels := range tex.Surfaces {
+		r, _, _, _ := levels[0][0].At(0, 0).RGBA()
+		if got := r >> 8; int(got) != face {
+			t.Errorf("face %d At(0,0).R = %d; want %d", face, got, face)
+		}
+	}
+}
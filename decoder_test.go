@@ -0,0 +1,142 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"testing"
+)
+
+func TestDecoderZeroValueMatchesDecode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImage(), &EncoderOptions{Format: FormatDXT5}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	want, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	var d Decoder
+	got, err := d.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("(&Decoder{}).Decode: %v", err)
+	}
+	if got.Bounds() != want.Bounds() {
+		t.Errorf("bounds = %v; want %v", got.Bounds(), want.Bounds())
+	}
+}
+
+func TestDecoderPremultiplyAlpha(t *testing.T) {
+	img := testImage()
+	// Force a transparent pixel so premultiplication has something to do.
+	img.Set(0, 0, testTransparentRed())
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, &EncoderOptions{Format: FormatRGBA}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	d := Decoder{PremultiplyAlpha: true}
+	got, err := d.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	r, g, b, a := got.At(0, 0).RGBA()
+	if a != 0x8080 {
+		t.Fatalf("A = %#x; want %#x", a, 0x8080)
+	}
+	// Straight red {0x80, 0, 0, 0x80} premultiplies to ~{0x40, 0, 0, 0x80}.
+	if r>>8 > 0x48 || r>>8 < 0x38 {
+		t.Errorf("R = %#x; want roughly 0x40", r>>8)
+	}
+	if g != 0 || b != 0 {
+		t.Errorf("G,B = %#x,%#x; want 0,0", g, b)
+	}
+}
+
+func testTransparentRed() color.RGBA {
+	return color.RGBA{R: 0x80, A: 0x80}
+}
+
+func TestDecoderStrictRejectsTruncatedFile(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImage(), &EncoderOptions{Format: FormatRGBA}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	truncated := buf.Bytes()[:buf.Len()-4]
+	d := Decoder{Strict: true}
+	_, err := d.Decode(bytes.NewReader(truncated))
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("err = %v; want wrapping ErrTruncated", err)
+	}
+}
+
+func TestDecoderStrictRejectsTruncatedDXT5(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImage(), &EncoderOptions{Format: FormatDXT5}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	// Truncate mid-block, rather than dropping a whole trailing block, so
+	// a naive slice index into the missing bytes would panic instead of
+	// returning ErrTruncated.
+	truncated := buf.Bytes()[:buf.Len()-2]
+	d := Decoder{Strict: true}
+	if _, err := d.Decode(bytes.NewReader(truncated)); !errors.Is(err, ErrTruncated) {
+		t.Errorf("err = %v; want wrapping ErrTruncated", err)
+	}
+}
+
+func TestDecoderStrictRejectsBadMagic(t *testing.T) {
+	d := Decoder{Strict: true}
+	_, err := d.Decode(bytes.NewReader([]byte("NOPE")))
+	if !errors.Is(err, ErrBadHeader) {
+		t.Errorf("err = %v; want wrapping ErrBadHeader", err)
+	}
+}
+
+func TestDecoderLenientIgnoresBadMipMapCount(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImage(), &EncoderOptions{Format: FormatRGBA}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	h, err := readHeader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("readHeader: %v", err)
+	}
+	h.mipMapCount = 4 // inconsistent: DDSD_MIPMAPCOUNT isn't set
+
+	var rewritten bytes.Buffer
+	if err := writeHeader(&rewritten, h); err != nil {
+		t.Fatalf("writeHeader: %v", err)
+	}
+	rewritten.Write(buf.Bytes()[4+headerSize:]) // magic + DDS_HEADER
+
+	if _, err := Decode(bytes.NewReader(rewritten.Bytes())); err != nil {
+		t.Errorf("Decode: %v; want lenient decode to ignore the inconsistency", err)
+	}
+
+	if _, err := (&Decoder{Strict: true}).Decode(bytes.NewReader(rewritten.Bytes())); !errors.Is(err, ErrBadHeader) {
+		t.Errorf("Strict Decode err = %v; want wrapping ErrBadHeader", err)
+	}
+}
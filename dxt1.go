@@ -0,0 +1,84 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"github.com/hugespaceship/dds/common"
+	"image/color"
+)
+
+// decompressDxt1 decompresses a DXT1 compressed slice of bytes into an
+// RGBA pixel buffer, the same way decompressDxt5 does, splitting block
+// rows across a worker pool sized to parallelism.
+func decompressDxt1(packed []byte, width, height, parallelism int) ([]byte, error) {
+	return decompressBlocks(packed, width, height, 8, parallelism, decompressDxt1Block)
+}
+
+// decompressDxt1Block decompresses a single 8-byte DXT1 block into the 4x4
+// pixels located at offsetX,offsetY in pix, an RGBA buffer
+// width*height*4 bytes long. When the block's first colour endpoint packs
+// to a value no greater than the second (c0 <= c1), the block is in
+// punch-through alpha mode: index 3 is fully transparent black instead of
+// an interpolated colour.
+func decompressDxt1Block(packed []byte, offsetX, offsetY, width, height int, pix []byte) error {
+	c0 := uint16(packed[0]) | uint16(packed[1])<<8
+	c1 := uint16(packed[2]) | uint16(packed[3])<<8
+	indices := uint32(packed[4]) | uint32(packed[5])<<8 | uint32(packed[6])<<16 | uint32(packed[7])<<24
+
+	var palette [4]color.RGBA
+	if c0 > c1 {
+		palette = blockPalette(c0, c1)
+	} else {
+		rgb0 := common.Rgb565toargb8888(c0)
+		rgb1 := common.Rgb565toargb8888(c1)
+		palette = [4]color.RGBA{
+			rgb0,
+			rgb1,
+			lerpRGB(rgb0, rgb1, 1, 1, 2),
+			{},
+		}
+	}
+
+	for j := 0; j < blockSize; j++ {
+		y := offsetY + j
+		if y >= height {
+			continue
+		}
+		for i := 0; i < blockSize; i++ {
+			x := offsetX + i
+			if x >= width {
+				continue
+			}
+
+			idx := (indices >> uint(2*(j*blockSize+i))) & 0x3
+			c := palette[idx]
+			if c0 <= c1 && idx == 3 {
+				c.A = 0
+			} else {
+				c.A = 255
+			}
+
+			o := (y*width + x) * 4
+			pix[o] = c.R
+			pix[o+1] = c.G
+			pix[o+2] = c.B
+			pix[o+3] = c.A
+		}
+	}
+
+	return nil
+}
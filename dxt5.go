@@ -3,40 +3,97 @@ package dds
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"github.com/hugespaceship/dds/common"
 	"image/color"
+	"io"
+	"runtime"
+	"sync"
 )
 
 const blockSize = 4
 
 // decompressDxt5
-// Decompress a Dxt5 compressed slice of bytes.
-// Decompresses block by block
+// Decompress a Dxt5 compressed slice of bytes into an RGBA pixel buffer.
 // Width and Height are required, as this information is impossible to derive with
-// 100% accuracy (e.g. 256x1024 cannot be distinguished from 512x512) from raw alone
-func decompressDxt5(packed []byte, width int, height int) ([]color.RGBA, error) {
-	unpacked := make([]color.RGBA, width*height)
-
-	blockCountX := int((width + 3) / blockSize)
-	blockCountY := int((height + 3) / blockSize)
-
-	offset := 0
-	for j := 0; j < blockCountY; j++ {
-		for i := 0; i < blockCountX; i++ {
-			if err := decompressDxt5Block(packed[offset+(i*16):], i*blockSize, j*blockSize, width, unpacked); err != nil {
-				return nil, err
-			}
+// 100% accuracy (e.g. 256x1024 cannot be distinguished from 512x512) from raw alone.
+//
+// Block rows are independent, so decompressBlocks splits the work across a
+// worker pool sized to parallelism.
+func decompressDxt5(packed []byte, width, height, parallelism int) ([]byte, error) {
+	return decompressBlocks(packed, width, height, 16, parallelism, decompressDxt5Block)
+}
+
+// decompressBlocks runs decodeBlock over every blockSize x blockSize block
+// of a width x height image packed at blockBytes bytes per block,
+// partitioning block rows across a worker pool sized to parallelism
+// (GOMAXPROCS(0) when parallelism <= 0). Each worker owns a disjoint set
+// of rows and writes straight into the returned RGBA pixel buffer, so no
+// locking is needed.
+func decompressBlocks(packed []byte, width, height, blockBytes, parallelism int, decodeBlock func(block []byte, offsetX, offsetY, width, height int, pix []byte) error) ([]byte, error) {
+	blockCountX := (width + blockSize - 1) / blockSize
+	blockCountY := (height + blockSize - 1) / blockSize
+	rowBytes := blockCountX * blockBytes
+
+	if len(packed) < blockCountY*rowBytes {
+		return nil, fmt.Errorf("decompressing blocks: %w", io.ErrUnexpectedEOF)
+	}
+
+	pix := make([]byte, width*height*4)
+
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if parallelism > blockCountY {
+		parallelism = blockCountY
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	rowsPerWorker := (blockCountY + parallelism - 1) / parallelism
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for w := 0; w < parallelism; w++ {
+		startRow := w * rowsPerWorker
+		endRow := startRow + rowsPerWorker
+		if endRow > blockCountY {
+			endRow = blockCountY
+		}
+		if startRow >= endRow {
+			continue
 		}
-		offset += blockCountX * 16
+
+		wg.Add(1)
+		go func(startRow, endRow int) {
+			defer wg.Done()
+			for by := startRow; by < endRow; by++ {
+				rowOffset := by * rowBytes
+				for bx := 0; bx < blockCountX; bx++ {
+					block := packed[rowOffset+bx*blockBytes:]
+					if err := decodeBlock(block, bx*blockSize, by*blockSize, width, height, pix); err != nil {
+						errOnce.Do(func() { firstErr = err })
+						return
+					}
+				}
+			}
+		}(startRow, endRow)
 	}
+	wg.Wait()
 
-	return unpacked, nil
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return pix, nil
 }
 
 // decompressDxt5Block
 // decompress a single dxt5 compressed block.
-// A single decompressed block is 4x4 pixels located at x,y location in the resultant image
-func decompressDxt5Block(packed []byte, offsetX int, offsetY int, width int, unpacked []color.RGBA) error {
+// A single decompressed block is 4x4 pixels located at x,y location in the
+// resultant image. The decoded pixels are written directly into pix, an
+// RGBA buffer width*height*4 bytes long.
+func decompressDxt5Block(packed []byte, offsetX int, offsetY int, width, height int, pix []byte) error {
 	var alpha0, alpha1 uint8
 	err := binary.Read(bytes.NewBuffer(packed[:1]), binary.LittleEndian, &alpha0)
 	if err != nil {
@@ -77,6 +134,10 @@ func decompressDxt5Block(packed []byte, offsetX int, offsetY int, width int, unp
 	}
 
 	for j := 0; j < blockSize; j++ {
+		y := offsetY + j
+		if y >= height {
+			continue
+		}
 		for i := 0; i < blockSize; i++ {
 			alphaCodeIndex := uint(3 * (4*j + i))
 			var alphaCode int
@@ -90,24 +151,7 @@ func decompressDxt5Block(packed []byte, offsetX int, offsetY int, width int, unp
 				alphaCode = int((alphaCode1 >> (alphaCodeIndex - 16)) & 0x07)
 			}
 
-			var finalAlpha uint8
-			if alphaCode == 0 {
-				finalAlpha = alpha0
-			} else if alphaCode == 1 {
-				finalAlpha = alpha1
-			} else {
-				if alpha0 > alpha1 {
-					finalAlpha = ((8-uint8(alphaCode))*alpha0 + (uint8(alphaCode)-1)*alpha1) / 7
-				} else {
-					if alphaCode == 6 {
-						finalAlpha = 0
-					} else if alphaCode == 7 {
-						finalAlpha = 255
-					} else {
-						finalAlpha = ((6-uint8(alphaCode))*alpha0 + (uint8(alphaCode)-1)*alpha1) / 5
-					}
-				}
-			}
+			finalAlpha := interpolateAlpha(alpha0, alpha1, alphaCode)
 
 			colorCode := (code >> uint32(2*(4*j+i))) & 0x03
 
@@ -131,19 +175,40 @@ func decompressDxt5Block(packed []byte, offsetX int, offsetY int, width int, unp
 				}
 			}
 
-			if finalAlpha != 255 {
-				a := 0
-				a -= 2
-			}
-
-			// Set alpha
 			finalColour.A = finalAlpha
 
 			if offsetX+i < width {
-				unpacked[(offsetY+j)*width+(offsetX+i)] = finalColour
+				o := (y*width + offsetX + i) * 4
+				pix[o] = finalColour.R
+				pix[o+1] = finalColour.G
+				pix[o+2] = finalColour.B
+				pix[o+3] = finalColour.A
 			}
 		}
 	}
 
 	return nil
 }
+
+// interpolateAlpha resolves a 3-bit DXT5 alpha code into the actual alpha
+// value it selects, given the block's two alpha endpoints. BC4 blocks use
+// an identical 8-byte layout and reuse this to decode their single
+// channel.
+func interpolateAlpha(alpha0, alpha1 uint8, alphaCode int) uint8 {
+	if alphaCode == 0 {
+		return alpha0
+	}
+	if alphaCode == 1 {
+		return alpha1
+	}
+	if alpha0 > alpha1 {
+		return ((8-uint8(alphaCode))*alpha0 + (uint8(alphaCode)-1)*alpha1) / 7
+	}
+	if alphaCode == 6 {
+		return 0
+	}
+	if alphaCode == 7 {
+		return 255
+	}
+	return ((6-uint8(alphaCode))*alpha0 + (uint8(alphaCode)-1)*alpha1) / 5
+}
@@ -0,0 +1,621 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"fmt"
+	"github.com/hugespaceship/dds/common"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// Format identifies the pixel layout an Encoder should write.
+type Format int
+
+const (
+	// FormatRGBA writes an uncompressed 32-bit RGBA surface.
+	FormatRGBA Format = iota
+	// FormatDXT1 writes a BC1/DXT1 compressed surface, with 1-bit alpha
+	// punch-through when the source image has any transparent pixel.
+	FormatDXT1
+	// FormatDXT5 writes a BC3/DXT5 compressed surface with interpolated alpha.
+	FormatDXT5
+)
+
+// Quality controls how hard the DXT1/DXT5 endpoint search tries to find a
+// good palette for each block.
+type Quality int
+
+const (
+	// QualityFast picks endpoints directly from the extremes of the block
+	// along its principal axis. Cheap, reasonable for most content.
+	QualityFast Quality = iota
+	// QualityCluster refines the fast endpoints with a least-squares
+	// cluster fit. Slower, produces noticeably fewer banding artifacts.
+	QualityCluster
+)
+
+// EncoderOptions configures Encode.
+type EncoderOptions struct {
+	// Format selects the output pixel format. Defaults to FormatDXT5.
+	Format Format
+	// Quality selects the endpoint-search heuristic for DXT1/DXT5. Defaults
+	// to QualityFast.
+	Quality Quality
+	// Mipmap, when true, generates a full mip pyramid down to 1x1 using a
+	// box filter and writes it alongside the top level.
+	Mipmap bool
+	// BufferPool, if non-nil, is used to obtain the scratch buffers used
+	// while encoding blocks.
+	BufferPool EncoderBufferPool
+}
+
+// EncoderBufferPool is implemented by types that can manage a pool of
+// reusable buffers for the encoder, mirroring image/png's
+// EncoderBufferPool.
+type EncoderBufferPool interface {
+	Get() *EncoderBuffer
+	Put(*EncoderBuffer)
+}
+
+// EncoderBuffer holds the scratch space an Encoder needs while compressing
+// one image: the staged 4x4 block of source pixels, the packed block bytes
+// once compressed, and the source-resolution buffer used while building the
+// mip pyramid. Reusing an EncoderBuffer across calls to Encode avoids
+// repeated allocation of all three.
+type EncoderBuffer struct {
+	block  [16]color.RGBA
+	packed [16]byte
+	mip    []color.RGBA
+}
+
+// Encoder writes images in the DirectDraw Surface format.
+type Encoder struct {
+	// Format selects the output pixel format. Defaults to FormatDXT5.
+	Format Format
+	// Quality selects the endpoint-search heuristic for DXT1/DXT5.
+	Quality Quality
+	// Mipmap, when true, generates and writes a full mip pyramid.
+	Mipmap bool
+	// BufferPool optionally reuses encoding scratch buffers across calls.
+	BufferPool EncoderBufferPool
+}
+
+// Encode writes m to w in the DDS format described by enc's options.
+func (enc *Encoder) Encode(w io.Writer, m image.Image) error {
+	var buf *EncoderBuffer
+	if enc.BufferPool != nil {
+		buf = enc.BufferPool.Get()
+		defer enc.BufferPool.Put(buf)
+	} else {
+		buf = &EncoderBuffer{}
+	}
+
+	b := m.Bounds()
+	w0, h0 := b.Dx(), b.Dy()
+
+	buf.mip = toRGBA(m)
+	levels := [][]color.RGBA{buf.mip}
+	if enc.Mipmap {
+		levels = append(levels, buildMipPyramid(levels[0], w0, h0)...)
+	}
+
+	h, err := enc.buildHeader(w0, h0, len(levels))
+	if err != nil {
+		return err
+	}
+	if err := writeHeader(w, h); err != nil {
+		return err
+	}
+
+	mw, mh := w0, h0
+	for _, level := range levels {
+		if err := enc.encodeLevel(w, level, mw, mh, buf); err != nil {
+			return err
+		}
+		mw, mh = nextMipSize(mw, mh)
+	}
+
+	return nil
+}
+
+func (enc *Encoder) buildHeader(w, h, mipCount int) (header, error) {
+	flags := uint32(headerFlagsTexture)
+	caps := uint32(capsTexture)
+	if mipCount > 1 {
+		flags |= headerFlagsMipMap
+		caps |= capsMipMap | capsComplex
+	}
+
+	var pf pixelFormat
+	switch enc.Format {
+	case FormatRGBA:
+		flags |= headerFlagsPitch
+		pf = pixelFormat{
+			flags:       pfRGB | pfAlphaPixels,
+			rgbBitCount: 32,
+			rBitMask:    0x00ff0000,
+			gBitMask:    0x0000ff00,
+			bBitMask:    0x000000ff,
+			aBitMask:    0xff000000,
+		}
+	case FormatDXT1:
+		flags |= headerFlagsLinearSize
+		pf = pixelFormat{flags: pfFourCC, fourCC: compressionTypeDXT1}
+	case FormatDXT5:
+		flags |= headerFlagsLinearSize
+		pf = pixelFormat{flags: pfFourCC, fourCC: compressionTypeDXT5}
+	default:
+		return header{}, fmt.Errorf("dds: unsupported encode format %d", enc.Format)
+	}
+
+	return header{
+		flags:             flags,
+		height:            uint32(h),
+		width:             uint32(w),
+		pitchOrLinearSize: uint32(surfaceSize(enc.Format, w, h)),
+		mipMapCount:       uint32(mipCount),
+		pixelFormat:       pf,
+		caps:              [4]uint32{caps, 0, 0, 0},
+	}, nil
+}
+
+func (enc *Encoder) encodeLevel(w io.Writer, pix []color.RGBA, width, height int, buf *EncoderBuffer) error {
+	switch enc.Format {
+	case FormatRGBA:
+		return encodeRGBA(w, pix)
+	case FormatDXT1:
+		return encodeBlocks(w, pix, width, height, buf, enc.Quality, true)
+	case FormatDXT5:
+		return encodeBlocks(w, pix, width, height, buf, enc.Quality, false)
+	default:
+		return fmt.Errorf("dds: unsupported encode format %d", enc.Format)
+	}
+}
+
+// Encode writes m to w using opt, or the default options (DXT5, fast
+// quality, no mipmaps) if opt is nil. It is a convenience wrapper akin to
+// jpeg.Encode, provided so callers can drop it in next to the existing
+// Decode.
+func Encode(w io.Writer, m image.Image, opt *EncoderOptions) error {
+	enc := &Encoder{Format: FormatDXT5}
+	if opt != nil {
+		enc.Format = opt.Format
+		enc.Quality = opt.Quality
+		enc.Mipmap = opt.Mipmap
+		enc.BufferPool = opt.BufferPool
+	}
+	return enc.Encode(w, m)
+}
+
+func toRGBA(m image.Image) []color.RGBA {
+	b := m.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := make([]color.RGBA, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, a := m.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			out[y*w+x] = color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(bl >> 8), A: uint8(a >> 8)}
+		}
+	}
+	return out
+}
+
+func nextMipSize(w, h int) (int, int) {
+	if w > 1 {
+		w /= 2
+	}
+	if h > 1 {
+		h /= 2
+	}
+	return w, h
+}
+
+// buildMipPyramid box-filters level 0 down to a 1x1 mip, returning every
+// level below the top one in order.
+func buildMipPyramid(level0 []color.RGBA, w, h int) [][]color.RGBA {
+	var levels [][]color.RGBA
+	src := level0
+	sw, sh := w, h
+	for sw > 1 || sh > 1 {
+		dw, dh := nextMipSize(sw, sh)
+		dst := make([]color.RGBA, dw*dh)
+		for y := 0; y < dh; y++ {
+			for x := 0; x < dw; x++ {
+				dst[y*dw+x] = boxFilter(src, sw, sh, x, y)
+			}
+		}
+		levels = append(levels, dst)
+		src, sw, sh = dst, dw, dh
+	}
+	return levels
+}
+
+func boxFilter(src []color.RGBA, sw, sh, dx, dy int) color.RGBA {
+	x0, y0 := dx*2, dy*2
+	var r, g, b, a, n int
+	for y := y0; y < y0+2 && y < sh; y++ {
+		for x := x0; x < x0+2 && x < sw; x++ {
+			p := src[y*sw+x]
+			r += int(p.R)
+			g += int(p.G)
+			b += int(p.B)
+			a += int(p.A)
+			n++
+		}
+	}
+	if n == 0 {
+		n = 1
+	}
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)}
+}
+
+func surfaceSize(f Format, w, h int) int {
+	switch f {
+	case FormatRGBA:
+		return w * h * 4
+	case FormatDXT1:
+		return max1(w+3, 4) / 4 * (max1(h+3, 4) / 4) * 8
+	default: // DXT5
+		return max1(w+3, 4) / 4 * (max1(h+3, 4) / 4) * 16
+	}
+}
+
+func max1(v, div int) int {
+	if v < div {
+		return div
+	}
+	return v
+}
+
+func encodeRGBA(w io.Writer, pix []color.RGBA) error {
+	buf := make([]byte, len(pix)*4)
+	for i, p := range pix {
+		// Matches the rBitMask/gBitMask/bBitMask/aBitMask written in
+		// buildHeader: BGRA byte order.
+		buf[i*4+0] = p.B
+		buf[i*4+1] = p.G
+		buf[i*4+2] = p.R
+		buf[i*4+3] = p.A
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// encodeBlocks compresses pix (width x height, row-major) into 4x4 DXT1 or
+// DXT5 blocks and writes them to w in raster order, reusing buf's scratch
+// block for every iteration.
+func encodeBlocks(w io.Writer, pix []color.RGBA, width, height int, buf *EncoderBuffer, q Quality, dxt1 bool) error {
+	blockCountX := (width + 3) / blockSize
+	blockCountY := (height + 3) / blockSize
+
+	blockBytes := 16
+	if dxt1 {
+		blockBytes = 8
+	}
+	out := make([]byte, blockCountX*blockCountY*blockBytes)
+
+	for by := 0; by < blockCountY; by++ {
+		for bx := 0; bx < blockCountX; bx++ {
+			gatherBlock(pix, width, height, bx, by, buf.block[:])
+			packed := buf.packed[:blockBytes]
+			if dxt1 {
+				encodeDXT1Block(buf.block[:], packed, q)
+			} else {
+				encodeDXT5Block(buf.block[:], packed, q)
+			}
+			copy(out[(by*blockCountX+bx)*blockBytes:], packed)
+		}
+	}
+
+	_, err := w.Write(out)
+	return err
+}
+
+func gatherBlock(pix []color.RGBA, width, height, bx, by int, block []color.RGBA) {
+	for j := 0; j < blockSize; j++ {
+		y := by*blockSize + j
+		if y >= height {
+			y = height - 1
+		}
+		for i := 0; i < blockSize; i++ {
+			x := bx*blockSize + i
+			if x >= width {
+				x = width - 1
+			}
+			block[j*blockSize+i] = pix[y*width+x]
+		}
+	}
+}
+
+// principalAxisEndpoints computes the covariance matrix of the block's
+// colours, takes its principal eigenvector via power iteration, and
+// projects the pixels onto it to find the two extreme colours.
+func principalAxisEndpoints(block []color.RGBA) (color.RGBA, color.RGBA) {
+	var mean [3]float64
+	for _, p := range block {
+		mean[0] += float64(p.R)
+		mean[1] += float64(p.G)
+		mean[2] += float64(p.B)
+	}
+	for i := range mean {
+		mean[i] /= float64(len(block))
+	}
+
+	var cov [3][3]float64
+	for _, p := range block {
+		d := [3]float64{float64(p.R) - mean[0], float64(p.G) - mean[1], float64(p.B) - mean[2]}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				cov[i][j] += d[i] * d[j]
+			}
+		}
+	}
+
+	axis := [3]float64{1, 1, 1}
+	for iter := 0; iter < 8; iter++ {
+		var next [3]float64
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				next[i] += cov[i][j] * axis[j]
+			}
+		}
+		n := math.Sqrt(next[0]*next[0] + next[1]*next[1] + next[2]*next[2])
+		if n < 1e-9 {
+			break
+		}
+		axis = [3]float64{next[0] / n, next[1] / n, next[2] / n}
+	}
+
+	minD, maxD := math.Inf(1), math.Inf(-1)
+	var minC, maxC color.RGBA
+	for _, p := range block {
+		d := [3]float64{float64(p.R) - mean[0], float64(p.G) - mean[1], float64(p.B) - mean[2]}
+		proj := d[0]*axis[0] + d[1]*axis[1] + d[2]*axis[2]
+		if proj < minD {
+			minD = proj
+			minC = p
+		}
+		if proj > maxD {
+			maxD = proj
+			maxC = p
+		}
+	}
+	return minC, maxC
+}
+
+func quantize565(c color.RGBA) uint16 {
+	r := uint16(c.R>>3) & 0x1f
+	g := uint16(c.G>>2) & 0x3f
+	b := uint16(c.B>>3) & 0x1f
+	return r<<11 | g<<5 | b
+}
+
+// blockPalette returns the 4-entry colour palette DXT1/DXT5 derive from a
+// pair of RGB565 endpoints, using the no-punch-through (4 interpolated
+// colour) interpretation.
+func blockPalette(c0, c1 uint16) [4]color.RGBA {
+	rgb0 := common.Rgb565toargb8888(c0)
+	rgb1 := common.Rgb565toargb8888(c1)
+	return [4]color.RGBA{
+		rgb0,
+		rgb1,
+		lerpRGB(rgb0, rgb1, 2, 1, 3),
+		lerpRGB(rgb0, rgb1, 1, 2, 3),
+	}
+}
+
+func lerpRGB(a, b color.RGBA, wa, wb, total int) color.RGBA {
+	return color.RGBA{
+		R: uint8((int(a.R)*wa + int(b.R)*wb) / total),
+		G: uint8((int(a.G)*wa + int(b.G)*wb) / total),
+		B: uint8((int(a.B)*wa + int(b.B)*wb) / total),
+		A: 255,
+	}
+}
+
+// closestIndex returns the palette entry closest to p by RGB distance.
+// excludeIndex3 skips palette[3], the dummy fully-transparent colour
+// encodeDXT1Block substitutes in punch-through blocks; callers pass this
+// for pixels that aren't themselves meant to be transparent, so an
+// opaque pixel can't be matched to that slot on RGB distance alone and
+// come back transparent.
+func closestIndex(p color.RGBA, palette [4]color.RGBA, excludeIndex3 bool) uint32 {
+	best, bestDist := 0, math.MaxInt64
+	for i, c := range palette {
+		if excludeIndex3 && i == 3 {
+			continue
+		}
+		dr := int(p.R) - int(c.R)
+		dg := int(p.G) - int(c.G)
+		db := int(p.B) - int(c.B)
+		dist := dr*dr + dg*dg + db*db
+		if dist < bestDist {
+			bestDist = dist
+			best = i
+		}
+	}
+	return uint32(best)
+}
+
+// hasTransparency reports whether block contains any non-fully-opaque
+// pixel, triggering DXT1's punch-through (3-colour + transparent) mode.
+func hasTransparency(block []color.RGBA) bool {
+	for _, p := range block {
+		if p.A < 255 {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeDXT1Block(block []color.RGBA, dst []byte, q Quality) {
+	c0, c1 := principalAxisEndpoints(block)
+	e0, e1 := quantize565(c0), quantize565(c1)
+
+	punchThrough := hasTransparency(block)
+	if punchThrough && e0 > e1 {
+		e0, e1 = e1, e0
+	} else if !punchThrough && e0 < e1 {
+		e0, e1 = e1, e0
+	}
+
+	var palette [4]color.RGBA
+	if punchThrough {
+		rgb0, rgb1 := common.Rgb565toargb8888(e0), common.Rgb565toargb8888(e1)
+		palette = [4]color.RGBA{rgb0, rgb1, lerpRGB(rgb0, rgb1, 1, 1, 2), {A: 0}}
+	} else {
+		palette = blockPalette(e0, e1)
+		if q == QualityCluster {
+			palette = refineClusterFit(block, palette)
+		}
+	}
+
+	dst[0] = byte(e0)
+	dst[1] = byte(e0 >> 8)
+	dst[2] = byte(e1)
+	dst[3] = byte(e1 >> 8)
+
+	var indices uint32
+	for i, p := range block {
+		var idx uint32
+		if punchThrough && p.A < 128 {
+			idx = 3
+		} else {
+			idx = closestIndex(p, palette, punchThrough)
+		}
+		indices |= idx << uint(2*i)
+	}
+	dst[4] = byte(indices)
+	dst[5] = byte(indices >> 8)
+	dst[6] = byte(indices >> 16)
+	dst[7] = byte(indices >> 24)
+}
+
+func encodeDXT5Block(block []color.RGBA, dst []byte, q Quality) {
+	a0, a1 := block[0].A, block[0].A
+	for _, p := range block {
+		if p.A < a0 {
+			a0 = p.A
+		}
+		if p.A > a1 {
+			a1 = p.A
+		}
+	}
+	dst[0], dst[1] = a1, a0 // a1 > a0 selects the 8-value (no 0/255 sentinel) interpolation.
+
+	var alphaBits uint64
+	for i, p := range block {
+		idx := closestAlphaIndex(p.A, a1, a0)
+		alphaBits |= uint64(idx) << uint(3*i)
+	}
+	for i := 0; i < 6; i++ {
+		dst[2+i] = byte(alphaBits >> uint(8*i))
+	}
+
+	c0, c1 := principalAxisEndpoints(block)
+	e0, e1 := quantize565(c0), quantize565(c1)
+	if e0 < e1 {
+		e0, e1 = e1, e0
+	}
+	palette := blockPalette(e0, e1)
+	if q == QualityCluster {
+		palette = refineClusterFit(block, palette)
+	}
+
+	dst[8] = byte(e0)
+	dst[9] = byte(e0 >> 8)
+	dst[10] = byte(e1)
+	dst[11] = byte(e1 >> 8)
+
+	var indices uint32
+	for i, p := range block {
+		indices |= closestIndex(p, palette, false) << uint(2*i)
+	}
+	dst[12] = byte(indices)
+	dst[13] = byte(indices >> 8)
+	dst[14] = byte(indices >> 16)
+	dst[15] = byte(indices >> 24)
+}
+
+// closestAlphaIndex picks the best of the 8 alpha values DXT5 interpolates
+// between a1 (index 0) and a0 (index 1) when a1 > a0.
+func closestAlphaIndex(a, a1, a0 uint8) uint64 {
+	best, bestDist := uint64(0), math.MaxInt32
+	for i := 0; i < 8; i++ {
+		var v int
+		switch i {
+		case 0:
+			v = int(a1)
+		case 1:
+			v = int(a0)
+		default:
+			v = (int(a1)*(8-i) + int(a0)*(i-1)) / 7
+		}
+		d := int(a) - v
+		if d < 0 {
+			d = -d
+		}
+		if d < bestDist {
+			bestDist = d
+			best = uint64(i)
+		}
+	}
+	return best
+}
+
+// refineClusterFit performs one least-squares refinement pass: assign
+// pixels to the nearest current palette entry, then recompute weighted
+// endpoints from that assignment and re-derive the interpolated palette.
+func refineClusterFit(block []color.RGBA, palette [4]color.RGBA) [4]color.RGBA {
+	var sum0, sum1 [3]float64
+	var w0, w1 float64
+	for _, p := range block {
+		idx := closestIndex(p, palette, false)
+		weight := 0.0
+		switch idx {
+		case 0:
+			weight = 1
+		case 2:
+			weight = 2.0 / 3.0
+		case 3:
+			weight = 1.0 / 3.0
+		}
+		sum0[0] += float64(p.R) * weight
+		sum0[1] += float64(p.G) * weight
+		sum0[2] += float64(p.B) * weight
+		w0 += weight
+
+		weight = 1 - weight
+		sum1[0] += float64(p.R) * weight
+		sum1[1] += float64(p.G) * weight
+		sum1[2] += float64(p.B) * weight
+		w1 += weight
+	}
+	if w0 < 1e-6 || w1 < 1e-6 {
+		return palette
+	}
+	c0 := color.RGBA{R: uint8(sum0[0] / w0), G: uint8(sum0[1] / w0), B: uint8(sum0[2] / w0), A: 255}
+	c1 := color.RGBA{R: uint8(sum1[0] / w1), G: uint8(sum1[1] / w1), B: uint8(sum1[2] / w1), A: 255}
+	e0, e1 := quantize565(c0), quantize565(c1)
+	if e0 < e1 {
+		e0, e1 = e1, e0
+	}
+	return blockPalette(e0, e1)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func testImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 32), G: uint8(y * 32), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestEncodeDecodeRGBA(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImage(), &EncoderOptions{Format: FormatRGBA}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.Bounds() != testImage().Bounds() {
+		t.Errorf("Decode bounds = %v; want %v", got.Bounds(), testImage().Bounds())
+	}
+}
+
+func TestEncodeDXT5RoundTrip(t *testing.T) {
+	src := testImage()
+	var buf bytes.Buffer
+	if err := Encode(&buf, src, &EncoderOptions{Format: FormatDXT5}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	b := got.Bounds()
+	if b.Dx() != 8 || b.Dy() != 8 {
+		t.Fatalf("Decode bounds = %v; want 8x8", b)
+	}
+
+	// DXT5 is lossy, so just check the decoded colours are in the
+	// right ballpark rather than exact.
+	r, g, bl, _ := got.At(0, 0).RGBA()
+	if r>>8 > 32 || g>>8 > 32 || bl>>8 < 96 {
+		t.Errorf("At(0,0) = (%d,%d,%d); want approximately (0,0,128)", r>>8, g>>8, bl>>8)
+	}
+}
+
+// TestEncodeDXT1PunchThroughPreservesOpaquePixels encodes a block with one
+// fully transparent corner and otherwise opaque, near-black pixels, which
+// used to be able to land on closestIndex's dummy transparent palette
+// slot by RGB distance alone and come back transparent after Decode.
+func TestEncodeDXT1PunchThroughPreservesOpaquePixels(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.RGBA{R: 4, G: 4, B: 4, A: 255})
+		}
+	}
+	img.Set(0, 0, color.RGBA{})
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, &EncoderOptions{Format: FormatDXT1}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if _, _, _, a := got.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("At(0,0) alpha = %#x; want fully transparent", a)
+	}
+	if _, _, _, a := got.At(3, 3).RGBA(); a>>8 != 0xff {
+		t.Errorf("At(3,3) alpha = %#x; want fully opaque", a)
+	}
+}
+
+// TestHasTransparencyTriggersOnPartialAlpha guards against regressing to
+// the old p.A < 128 threshold, which silently treated partially
+// transparent pixels as opaque instead of punching through.
+func TestHasTransparencyTriggersOnPartialAlpha(t *testing.T) {
+	block := make([]color.RGBA, 16)
+	for i := range block {
+		block[i] = color.RGBA{A: 255}
+	}
+	block[0].A = 200
+	if !hasTransparency(block) {
+		t.Error("hasTransparency = false; want true for a block with a partially transparent pixel")
+	}
+}
+
+func TestEncodeWithMipmap(t *testing.T) {
+	var buf bytes.Buffer
+	opt := &EncoderOptions{Format: FormatRGBA, Mipmap: true}
+	if err := Encode(&buf, testImage(), opt); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	h, err := readHeader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("readHeader: %v", err)
+	}
+	// 8x8 -> 4x4 -> 2x2 -> 1x1 is 4 levels.
+	if h.mipMapCount != 4 {
+		t.Errorf("mipMapCount = %d; want 4", h.mipMapCount)
+	}
+}
@@ -26,7 +26,6 @@ package dds
 
 import (
 	"fmt"
-	"github.com/mauserzjeh/dxt"
 	"image"
 	"image/color"
 	"io"
@@ -108,6 +107,14 @@ func Decode(r io.Reader) (image.Image, error) {
 		return nil, err
 	}
 
+	return decodeSurface(h, r)
+}
+
+// decodeSurface decodes a single surface's pixel data from r, using h to
+// determine its format and (via h.width/h.height) its dimensions. Callers
+// iterating multiple surfaces or mip levels, such as DecodeAll, pass a
+// copy of h with width/height overridden to that level's dimensions.
+func decodeSurface(h header, r io.Reader) (image.Image, error) {
 	switch h.pixelFormat.fourCC {
 	case compressionTypeNone:
 		return decodeUncompressedDDS(h, r)
@@ -115,37 +122,90 @@ func Decode(r io.Reader) (image.Image, error) {
 		return decodeDXT1DDS(h, r)
 	case compressionTypeDXT5:
 		return decodeDXT5DDS(h, r)
+	case compressionTypeATI1:
+		return decodeBC4DDS(h, r)
+	case compressionTypeATI2:
+		return decodeBC5DDS(h, r)
+	case compressionTypeDX10:
+		return decodeDX10DDS(h, r)
 	default:
 		return nil, fmt.Errorf("unsupported compression format %x", h.pixelFormat.fourCC)
 	}
 
 }
 
-func decodeDXT5DDS(h header, r io.Reader) (image.Image, error) {
+// decodeDX10DDS routes decoding by the DXGI_FORMAT carried in the
+// trailing DDS_HEADER_DXT10, for files that use the modern extended
+// header instead of a legacy fourCC.
+//
+// Volume textures (resourceDimension == TEXTURE3D) and cubemaps
+// (miscFlag & TEXTURECUBE) carry more than one surface; Decode only ever
+// returns the first one; DecodeAll exposes the rest.
+func decodeDX10DDS(h header, r io.Reader) (image.Image, error) {
+	if h.dx10 == nil {
+		return nil, fmt.Errorf("DX10 fourCC set but no DDS_HEADER_DXT10 was parsed")
+	}
+
+	switch h.dx10.dxgiFormat {
+	case dxgiFormatBC4Unorm, dxgiFormatBC4Snorm:
+		return decodeBC4DDS(h, r)
+	case dxgiFormatBC5Unorm, dxgiFormatBC5Snorm:
+		return decodeBC5DDS(h, r)
+	case dxgiFormatBC7Unorm, dxgiFormatBC7Srgb:
+		imgBytes, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return decompressBC7(imgBytes, int(h.width), int(h.height))
+	case dxgiFormatBC6HUF16:
+		imgBytes, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return decompressBC6H(imgBytes, int(h.width), int(h.height), false)
+	case dxgiFormatBC6HSF16:
+		imgBytes, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return decompressBC6H(imgBytes, int(h.width), int(h.height), true)
+	default:
+		return nil, fmt.Errorf("unsupported DXGI_FORMAT %d", h.dx10.dxgiFormat)
+	}
+}
+
+func decodeBC4DDS(h header, r io.Reader) (image.Image, error) {
 	imgBytes, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	return decompressBC4(imgBytes, int(h.width), int(h.height))
+}
 
-	rgbaPixels, err := decompressDxt5(imgBytes, int(h.width), int(h.height))
+func decodeBC5DDS(h header, r io.Reader) (image.Image, error) {
+	imgBytes, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	return decompressBC5(imgBytes, int(h.width), int(h.height))
+}
 
-	rgbaBytes := make([]byte, len(rgbaPixels)*4)
-	for i, pixel := range rgbaPixels {
-		bi := i * 4
-		rgbaBytes[bi] = pixel.R
-		rgbaBytes[bi+1] = pixel.G
-		rgbaBytes[bi+2] = pixel.B
-		rgbaBytes[bi+3] = pixel.A
+func decodeDXT5DDS(h header, r io.Reader) (image.Image, error) {
+	imgBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	pix, err := decompressDxt5(imgBytes, int(h.width), int(h.height), 0)
+	if err != nil {
+		return nil, err
 	}
 
 	return &image.RGBA{
-		Pix:    rgbaBytes,
+		Pix:    pix,
 		Stride: int(4 * h.width),
 		Rect:   image.Rect(0, 0, int(h.width), int(h.height)),
-	}, err
+	}, nil
 }
 
 func decodeUncompressedDDS(h header, r io.Reader) (image.Image, error) {
@@ -156,7 +216,7 @@ func decodeUncompressedDDS(h header, r io.Reader) (image.Image, error) {
 	pitch := (h.width*h.pixelFormat.rgbBitCount + 7) / 8
 	buf := make([]byte, pitch*h.height)
 	if _, err := io.ReadFull(r, buf); err != nil {
-		return nil, fmt.Errorf("reading image: %v", err)
+		return nil, fmt.Errorf("reading image: %w", err)
 	}
 	stride := h.pixelFormat.rgbBitCount / 8
 
@@ -174,21 +234,20 @@ func decodeUncompressedDDS(h header, r io.Reader) (image.Image, error) {
 	}, nil
 }
 
-// This is a lazy hack to get dxt1 to work with this library
 func decodeDXT1DDS(h header, r io.Reader) (image.Image, error) {
 	imgBytes, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
-	rgbaBytes, err := dxt.DecodeDXT1(imgBytes, uint(h.width), uint(h.height))
+	pix, err := decompressDxt1(imgBytes, int(h.width), int(h.height), 0)
 	if err != nil {
 		return nil, err
 	}
 
 	return &image.RGBA{
-		Pix:    rgbaBytes,
+		Pix:    pix,
 		Stride: int(4 * h.width),
 		Rect:   image.Rect(0, 0, int(h.width), int(h.height)),
-	}, err
+	}, nil
 }
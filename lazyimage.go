@@ -0,0 +1,205 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"container/list"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+)
+
+// DecodeOptions controls how DecodeWithOptions trades decode-time CPU
+// and memory for latency. Only DXT1 and DXT5 honour these; every other
+// format is decoded the same way Decode would decode it.
+type DecodeOptions struct {
+	// Lazy, when true, returns a *LazyImage that decodes each block the
+	// first time one of its pixels is read, instead of eagerly decoding
+	// the whole image up front.
+	Lazy bool
+
+	// Parallelism is the number of goroutines eager decoding splits
+	// block rows across. Zero means runtime.GOMAXPROCS(0). Ignored when
+	// Lazy is true, since lazy decoding is driven by At and happens on
+	// the caller's goroutine.
+	Parallelism int
+}
+
+// DecodeWithOptions decodes r the same way Decode does, but lets the
+// caller pick between Decode's eager, parallel block decoding and
+// LazyImage's on-demand decoding.
+func DecodeWithOptions(r io.Reader, opt DecodeOptions) (image.Image, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dxt1 := h.pixelFormat.fourCC == compressionTypeDXT1
+	dxt5 := h.pixelFormat.fourCC == compressionTypeDXT5
+	if !dxt1 && !dxt5 {
+		return decodeSurface(h, r)
+	}
+
+	imgBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Lazy {
+		return NewLazyImage(imgBytes, int(h.width), int(h.height), dxt1), nil
+	}
+
+	var pix []byte
+	if dxt1 {
+		pix, err = decompressDxt1(imgBytes, int(h.width), int(h.height), opt.Parallelism)
+	} else {
+		pix, err = decompressDxt5(imgBytes, int(h.width), int(h.height), opt.Parallelism)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &image.RGBA{
+		Pix:    pix,
+		Stride: int(4 * h.width),
+		Rect:   image.Rect(0, 0, int(h.width), int(h.height)),
+	}, nil
+}
+
+// lazyBlockCacheSize bounds how many decoded 4x4 blocks LazyImage keeps
+// around before evicting the least recently used one.
+const lazyBlockCacheSize = 64
+
+type lazyBlockKey struct {
+	bx, by int
+}
+
+type lazyBlockEntry struct {
+	key lazyBlockKey
+	pix [blockSize][blockSize]color.RGBA
+}
+
+// LazyImage is an image.Image backed by raw DXT1 or DXT5 compressed data.
+// Blocks are decoded on first access from At and kept in a small
+// least-recently-used cache, so a reader that only touches part of the
+// image never pays to decode the rest of it.
+type LazyImage struct {
+	packed                   []byte
+	width, height            int
+	blockCountX, blockCountY int
+	blockBytes               int
+	dxt1                     bool
+
+	mu    sync.Mutex
+	cache map[lazyBlockKey]*list.Element
+	order *list.List // front = most recently used
+}
+
+// NewLazyImage wraps packed, width x height DXT1 or DXT5 compressed pixel
+// data (dxt1 selects which) in a LazyImage.
+func NewLazyImage(packed []byte, width, height int, dxt1 bool) *LazyImage {
+	blockBytes := 16
+	if dxt1 {
+		blockBytes = 8
+	}
+	return &LazyImage{
+		packed:      packed,
+		width:       width,
+		height:      height,
+		blockCountX: (width + blockSize - 1) / blockSize,
+		blockCountY: (height + blockSize - 1) / blockSize,
+		blockBytes:  blockBytes,
+		dxt1:        dxt1,
+		cache:       make(map[lazyBlockKey]*list.Element),
+		order:       list.New(),
+	}
+}
+
+func (l *LazyImage) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (l *LazyImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, l.width, l.height)
+}
+
+func (l *LazyImage) At(x, y int) color.Color {
+	if x < 0 || y < 0 || x >= l.width || y >= l.height {
+		return color.RGBA{}
+	}
+	block := l.block(x/blockSize, y/blockSize)
+	return block[y%blockSize][x%blockSize]
+}
+
+// block returns the decoded pixels of the block at (bx, by), decoding
+// and caching it first if it isn't already cached.
+func (l *LazyImage) block(bx, by int) [blockSize][blockSize]color.RGBA {
+	key := lazyBlockKey{bx, by}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.cache[key]; ok {
+		l.order.MoveToFront(el)
+		return el.Value.(*lazyBlockEntry).pix
+	}
+
+	pix := l.decodeBlock(bx, by)
+	el := l.order.PushFront(&lazyBlockEntry{key: key, pix: pix})
+	l.cache[key] = el
+
+	if l.order.Len() > lazyBlockCacheSize {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.cache, oldest.Value.(*lazyBlockEntry).key)
+	}
+
+	return pix
+}
+
+// decodeBlock decodes the single block at (bx, by) into its own 4x4
+// scratch buffer, reusing the same block decoders decompressDxt1 and
+// decompressDxt5 decode their rows with.
+func (l *LazyImage) decodeBlock(bx, by int) [blockSize][blockSize]color.RGBA {
+	rowBytes := l.blockCountX * l.blockBytes
+	offset := by*rowBytes + bx*l.blockBytes
+
+	var pix [blockSize][blockSize]color.RGBA
+	if offset+l.blockBytes > len(l.packed) {
+		// Truncated payload: At already returns the zero colour for
+		// out-of-bounds coordinates, so do the same here rather than
+		// panicking on a short slice.
+		return pix
+	}
+	block := l.packed[offset:]
+
+	scratch := make([]byte, blockSize*blockSize*4)
+	if l.dxt1 {
+		decompressDxt1Block(block, 0, 0, blockSize, blockSize, scratch)
+	} else {
+		decompressDxt5Block(block, 0, 0, blockSize, blockSize, scratch)
+	}
+
+	for j := 0; j < blockSize; j++ {
+		for i := 0; i < blockSize; i++ {
+			o := (j*blockSize + i) * 4
+			pix[j][i] = color.RGBA{R: scratch[o], G: scratch[o+1], B: scratch[o+2], A: scratch[o+3]}
+		}
+	}
+	return pix
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2024 Henry Asbridge
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeWithOptionsLazyMatchesEager(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImage(), &EncoderOptions{Format: FormatDXT5}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	eager, err := DecodeWithOptions(bytes.NewReader(buf.Bytes()), DecodeOptions{Parallelism: 2})
+	if err != nil {
+		t.Fatalf("DecodeWithOptions (eager): %v", err)
+	}
+	lazy, err := DecodeWithOptions(bytes.NewReader(buf.Bytes()), DecodeOptions{Lazy: true})
+	if err != nil {
+		t.Fatalf("DecodeWithOptions (lazy): %v", err)
+	}
+
+	if _, ok := lazy.(*LazyImage); !ok {
+		t.Fatalf("DecodeWithOptions with Lazy: true returned %T; want *LazyImage", lazy)
+	}
+
+	b := eager.Bounds()
+	if lazy.Bounds() != b {
+		t.Fatalf("lazy bounds = %v; want %v", lazy.Bounds(), b)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			wantR, wantG, wantB, wantA := eager.At(x, y).RGBA()
+			gotR, gotG, gotB, gotA := lazy.At(x, y).RGBA()
+			if wantR != gotR || wantG != gotG || wantB != gotB || wantA != gotA {
+				t.Fatalf("At(%d,%d) = %v; want %v", x, y, lazy.At(x, y), eager.At(x, y))
+			}
+		}
+	}
+}
+
+func TestLazyImageRevisitsCachedBlock(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImage(), &EncoderOptions{Format: FormatDXT1}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	lazy, err := DecodeWithOptions(bytes.NewReader(buf.Bytes()), DecodeOptions{Lazy: true})
+	if err != nil {
+		t.Fatalf("DecodeWithOptions: %v", err)
+	}
+
+	first := lazy.(*LazyImage).At(0, 0)
+	lazy.(*LazyImage).At(1, 1) // same block, populates the cache entry
+	if again := lazy.(*LazyImage).At(0, 0); again != first {
+		t.Errorf("At(0,0) changed between reads: %v then %v", first, again)
+	}
+}
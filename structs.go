@@ -0,0 +1,252 @@
+/*
+Copyright 2017 Luke Granger-Brown
+Modified for use with HugeSpaceship by Henry Asbridge in 2024
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dds
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	headerSize      = 124 // Size of DDS_HEADER structure
+	pixelFormatSize = 32  // Size of DDS_PIXELFORMAT structure
+
+	dCaps        = 0x1
+	dHeight      = 0x2
+	dWidth       = 0x4
+	dPitch       = 0x8
+	dPixelFormat = 0x1000
+	dMipMapCount = 0x20000
+	dLinearSize  = 0x80000
+	dDepth       = 0x800000
+
+	pfAlphaPixels = 0x1
+	pfAlpha       = 0x2
+	pfFourCC      = 0x4
+	pfRGB         = 0x40
+	pfYUV         = 0x200
+	pfLuminance   = 0x20000
+
+	headerFlagsTexture    = dCaps | dHeight | dWidth | dPixelFormat
+	headerFlagsMipMap     = dMipMapCount
+	headerFlagsVolume     = dDepth
+	headerFlagsPitch      = dPitch
+	headerFlagsLinearSize = dLinearSize
+
+	compressionTypeNone = 0
+	compressionTypeDXT1 = 827611204
+	compressionTypeDXT5 = 894720068
+	compressionTypeDX10 = 808540228
+	compressionTypeATI1 = 826889281 // "ATI1", legacy BC4 fourCC
+	compressionTypeATI2 = 843666497 // "ATI2", legacy BC5 fourCC
+
+	capsTexture = 0x1000
+	capsMipMap  = 0x400000
+	capsComplex = 0x8
+)
+
+// DXGI_FORMAT values relevant to block-compressed textures. Only the
+// formats Decode knows how to handle are named; the rest pass through as
+// their raw numeric value.
+const (
+	dxgiFormatBC4Unorm = 80
+	dxgiFormatBC4Snorm = 81
+	dxgiFormatBC5Unorm = 83
+	dxgiFormatBC5Snorm = 84
+	dxgiFormatBC6HUF16 = 95
+	dxgiFormatBC6HSF16 = 96
+	dxgiFormatBC7Unorm = 98
+	dxgiFormatBC7Srgb  = 99
+)
+
+// resourceDimension values from D3D10_RESOURCE_DIMENSION.
+const (
+	resourceDimensionTexture1D = 2
+	resourceDimensionTexture2D = 3
+	resourceDimensionTexture3D = 4
+)
+
+// miscFlag bit indicating the DX10 resource is a cubemap.
+const miscFlagTextureCube = 0x4
+
+// header.caps[1] (DDSCAPS2) bits relevant to surface enumeration.
+const (
+	caps2Cubemap = 0x200
+	caps2Volume  = 0x200000
+)
+
+// headerDXT10 mirrors DDS_HEADER_DXT10, present immediately after the
+// DDS_HEADER when pixelFormat.fourCC is compressionTypeDX10.
+type headerDXT10 struct {
+	dxgiFormat        uint32
+	resourceDimension uint32
+	miscFlag          uint32
+	arraySize         uint32
+	miscFlags2        uint32
+}
+
+type pixelFormat struct {
+	flags       uint32
+	fourCC      uint32
+	rgbBitCount uint32
+	rBitMask    uint32
+	gBitMask    uint32
+	bBitMask    uint32
+	aBitMask    uint32
+}
+
+type header struct {
+	flags             uint32
+	height            uint32
+	width             uint32
+	pitchOrLinearSize uint32
+	depth             uint32
+	mipMapCount       uint32
+	pixelFormat       pixelFormat
+	caps              [4]uint32
+
+	// dx10 is non-nil when pixelFormat.fourCC is compressionTypeDX10, and
+	// holds the trailing DDS_HEADER_DXT10 that follows the regular header
+	// in that case.
+	dx10 *headerDXT10
+}
+
+func readHeader(r io.Reader) (header, error) {
+	var buf []byte
+
+	// read the magic
+	buf = make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return header{}, fmt.Errorf("reading magic: %w", err)
+	}
+	if buf[0] != 'D' || buf[1] != 'D' || buf[2] != 'S' || buf[3] != ' ' {
+		return header{}, fmt.Errorf("magic is incorrect, expected \"DDS \", got %v", buf)
+	}
+
+	// read the dds file header
+	buf = make([]byte, 124)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return header{}, fmt.Errorf("reading header: %w", err)
+	}
+
+	var t uint32
+	if t, buf = readDWORD(buf); t != headerSize {
+		return header{}, fmt.Errorf("DDS_HEADER reports wrong size, expected %d, got %d", t, headerSize)
+	}
+
+	var h header
+	h.flags, buf = readDWORD(buf)
+	h.height, buf = readDWORD(buf)
+	h.width, buf = readDWORD(buf)
+	h.pitchOrLinearSize, buf = readDWORD(buf)
+	h.depth, buf = readDWORD(buf)
+	h.mipMapCount, buf = readDWORD(buf)
+	buf = buf[11*4:] // strip off reserved1
+	if t, buf = readDWORD(buf); t != pixelFormatSize {
+		return header{}, fmt.Errorf("DDS_PIXEL_FORMAT reports wrong size, expected %d, got %d", t, pixelFormatSize)
+	}
+	pf := h.pixelFormat
+	pf.flags, buf = readDWORD(buf)
+	pf.fourCC, buf = readDWORD(buf)
+	pf.rgbBitCount, buf = readDWORD(buf)
+	pf.rBitMask, buf = readDWORD(buf)
+	pf.gBitMask, buf = readDWORD(buf)
+	pf.bBitMask, buf = readDWORD(buf)
+	pf.aBitMask, buf = readDWORD(buf)
+	h.pixelFormat = pf
+	for n := 0; n < 4; n++ {
+		h.caps[n], buf = readDWORD(buf)
+	}
+	buf = buf[4:] // strip off reserved2
+	if len(buf) > 0 {
+		return header{}, fmt.Errorf("trailing garbage remains: %d bytes", len(buf))
+	}
+
+	// check that flags is valid
+	if h.flags&headerFlagsTexture != headerFlagsTexture {
+		return header{}, fmt.Errorf("DDS_HEADER reports that one or more required fields are not set: flags was %x; should at least have %x set", h.flags, headerFlagsTexture)
+	}
+
+	if h.pixelFormat.fourCC == compressionTypeDX10 {
+		dx10, err := readHeaderDXT10(r)
+		if err != nil {
+			return header{}, err
+		}
+		h.dx10 = &dx10
+	}
+
+	return h, nil
+}
+
+// readHeaderDXT10 reads the DDS_HEADER_DXT10 extension that follows
+// DDS_HEADER whenever the legacy pixel format's fourCC is "DX10".
+func readHeaderDXT10(r io.Reader) (headerDXT10, error) {
+	buf := make([]byte, 20)
+	if n, err := io.ReadFull(r, buf); n != 20 || err != nil {
+		return headerDXT10{}, fmt.Errorf("reading DDS_HEADER_DXT10: %v", err)
+	}
+
+	var dx10 headerDXT10
+	dx10.dxgiFormat, buf = readDWORD(buf)
+	dx10.resourceDimension, buf = readDWORD(buf)
+	dx10.miscFlag, buf = readDWORD(buf)
+	dx10.arraySize, buf = readDWORD(buf)
+	dx10.miscFlags2, buf = readDWORD(buf)
+
+	if dx10.arraySize == 0 {
+		return headerDXT10{}, fmt.Errorf("DDS_HEADER_DXT10 reports arraySize of 0")
+	}
+
+	return dx10, nil
+}
+
+// writeHeader writes the magic followed by a DDS_HEADER in the layout
+// readHeader expects, so that files produced by Encode round-trip
+// through Decode.
+func writeHeader(w io.Writer, h header) error {
+	if _, err := w.Write([]byte("DDS ")); err != nil {
+		return fmt.Errorf("writing magic: %v", err)
+	}
+
+	var buf []byte
+	buf = append(buf, writeDWORD(headerSize)...)
+	buf = append(buf, writeDWORD(h.flags)...)
+	buf = append(buf, writeDWORD(h.height)...)
+	buf = append(buf, writeDWORD(h.width)...)
+	buf = append(buf, writeDWORD(h.pitchOrLinearSize)...)
+	buf = append(buf, writeDWORD(h.depth)...)
+	buf = append(buf, writeDWORD(h.mipMapCount)...)
+	buf = append(buf, make([]byte, 11*4)...) // reserved1
+	buf = append(buf, writeDWORD(pixelFormatSize)...)
+	buf = append(buf, writeDWORD(h.pixelFormat.flags)...)
+	buf = append(buf, writeDWORD(h.pixelFormat.fourCC)...)
+	buf = append(buf, writeDWORD(h.pixelFormat.rgbBitCount)...)
+	buf = append(buf, writeDWORD(h.pixelFormat.rBitMask)...)
+	buf = append(buf, writeDWORD(h.pixelFormat.gBitMask)...)
+	buf = append(buf, writeDWORD(h.pixelFormat.bBitMask)...)
+	buf = append(buf, writeDWORD(h.pixelFormat.aBitMask)...)
+	for _, c := range h.caps {
+		buf = append(buf, writeDWORD(c)...)
+	}
+	buf = append(buf, make([]byte, 4)...) // reserved2
+
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("writing header: %v", err)
+	}
+	return nil
+}